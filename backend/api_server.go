@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/tomaszkaliciak/weight-insider/backend/apiserver"
+)
+
+// runAPIServer starts an HTTP server on addr exposing WeightInsiderData over
+// a REST API, so the frontend can read live data instead of a static
+// data.json and third-party tools can push measurements.
+func runAPIServer(addr string) error {
+	credentials, err := loadCredentials(CredentialsFile)
+	if err != nil {
+		return fmt.Errorf("failed to load credentials: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/weights", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handleGetWeights(w, r)
+		case http.MethodPost:
+			apiserver.RequireBearer(credentials.APIToken, http.HandlerFunc(handlePostWeight)).ServeHTTP(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/bodyfat", handleGetBodyFat)
+	mux.HandleFunc("/export.csv", handleExportCSV)
+
+	log.Printf("api: listening on %s", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+func handleGetWeights(w http.ResponseWriter, r *http.Request) {
+	dataFileMu.Lock()
+	data, err := loadOrInitData(DataJSONPath)
+	dataFileMu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	points := filterDateRange(data.Weights, r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err := apiserver.WriteSeries(w, r, []apiserver.Series{{Name: "weights", Points: points}}); err != nil {
+		log.Printf("api: failed to write weights response: %v", err)
+	}
+}
+
+func handleGetBodyFat(w http.ResponseWriter, r *http.Request) {
+	dataFileMu.Lock()
+	data, err := loadOrInitData(DataJSONPath)
+	dataFileMu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	points := filterDateRange(data.BodyFat, r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err := apiserver.WriteSeries(w, r, []apiserver.Series{{Name: "bodyfat", Points: points}}); err != nil {
+		log.Printf("api: failed to write bodyfat response: %v", err)
+	}
+}
+
+// filterDateRange returns the subset of points whose date falls within
+// [from, to] (either bound may be empty), relying on "2006-01-02" dates
+// sorting the same lexicographically as chronologically.
+func filterDateRange(points map[string]float64, from, to string) map[string]float64 {
+	if from == "" && to == "" {
+		return points
+	}
+
+	filtered := make(map[string]float64, len(points))
+	for date, value := range points {
+		if from != "" && date < from {
+			continue
+		}
+		if to != "" && date > to {
+			continue
+		}
+		filtered[date] = value
+	}
+	return filtered
+}
+
+type weightEntry struct {
+	Date   string  `json:"date"`
+	Weight float64 `json:"weight"`
+}
+
+func handlePostWeight(w http.ResponseWriter, r *http.Request) {
+	var entry weightEntry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if _, err := time.Parse("2006-01-02", entry.Date); err != nil {
+		http.Error(w, fmt.Sprintf("invalid date %q: %v", entry.Date, err), http.StatusBadRequest)
+		return
+	}
+
+	dataFileMu.Lock()
+	defer dataFileMu.Unlock()
+
+	data, err := loadOrInitData(DataJSONPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if existing, ok := data.Weights[entry.Date]; ok && existing != entry.Weight {
+		data.History[entry.Date] = append(data.History[entry.Date], WeightHistoryEntry{Value: existing, RecordedAt: time.Now().UTC()})
+	}
+	data.Weights[entry.Date] = entry.Weight
+
+	if err := saveData(DataJSONPath, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleExportCSV(w http.ResponseWriter, r *http.Request) {
+	dataFileMu.Lock()
+	data, err := loadOrInitData(DataJSONPath)
+	dataFileMu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	writer := csv.NewWriter(w)
+	defer writer.Flush()
+
+	header := []string{"date", "weight", "bodyFat", "calorieIntake", "googleFitExpenditure", "fitbitExpenditure", "steps", "distanceMeters", "restingHR", "sleepMinutes"}
+	if err := writer.Write(header); err != nil {
+		log.Printf("api: failed to write export.csv header: %v", err)
+		return
+	}
+
+	for _, date := range allDates(data) {
+		row := []string{
+			date,
+			formatFloat(data.Weights, date),
+			formatFloat(data.BodyFat, date),
+			formatInt(data.CalorieIntake, date),
+			formatInt(data.GoogleFitExpenditure, date),
+			formatInt(data.FitbitExpenditure, date),
+			formatInt(data.Steps, date),
+			formatFloat(data.DistanceMeters, date),
+			formatInt(data.RestingHR, date),
+			formatInt(data.SleepMinutes, date),
+		}
+		if err := writer.Write(row); err != nil {
+			log.Printf("api: failed to write export.csv row for %s: %v", date, err)
+			return
+		}
+	}
+}
+
+// allDates returns the union of every date any field of data has an entry
+// for, sorted ascending.
+func allDates(data *WeightInsiderData) []string {
+	seen := make(map[string]struct{})
+	for date := range data.Weights {
+		seen[date] = struct{}{}
+	}
+	for date := range data.BodyFat {
+		seen[date] = struct{}{}
+	}
+	for date := range data.CalorieIntake {
+		seen[date] = struct{}{}
+	}
+	for date := range data.GoogleFitExpenditure {
+		seen[date] = struct{}{}
+	}
+	for date := range data.FitbitExpenditure {
+		seen[date] = struct{}{}
+	}
+	for date := range data.Steps {
+		seen[date] = struct{}{}
+	}
+	for date := range data.DistanceMeters {
+		seen[date] = struct{}{}
+	}
+	for date := range data.RestingHR {
+		seen[date] = struct{}{}
+	}
+	for date := range data.SleepMinutes {
+		seen[date] = struct{}{}
+	}
+
+	dates := make([]string, 0, len(seen))
+	for date := range seen {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	return dates
+}
+
+func formatFloat(values map[string]float64, date string) string {
+	value, ok := values[date]
+	if !ok {
+		return ""
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func formatInt(values map[string]int, date string) string {
+	value, ok := values[date]
+	if !ok {
+		return ""
+	}
+	return strconv.Itoa(value)
+}
@@ -0,0 +1,136 @@
+// Package apiserver provides content-negotiated encoders for serving
+// WeightInsiderData series (weights, body fat, ...) over HTTP as JSON, CSV,
+// or Prometheus exposition format, plus a bearer-token gate for write
+// endpoints.
+package apiserver
+
+import (
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Series is a single named metric, keyed by "2006-01-02" date.
+type Series struct {
+	Name   string
+	Points map[string]float64
+}
+
+type format int
+
+const (
+	formatJSON format = iota
+	formatCSV
+	formatPrometheus
+)
+
+// negotiate picks an encoding based on the request's Accept header,
+// defaulting to JSON when it's absent, empty, or unrecognized.
+func negotiate(r *http.Request) format {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv":
+			return formatCSV
+		case "text/plain":
+			return formatPrometheus
+		case "application/json", "*/*":
+			return formatJSON
+		}
+	}
+	return formatJSON
+}
+
+// WriteSeries encodes series in whichever format the request's Accept
+// header negotiates to and writes it to w.
+func WriteSeries(w http.ResponseWriter, r *http.Request, series []Series) error {
+	switch negotiate(r) {
+	case formatCSV:
+		return writeCSV(w, series)
+	case formatPrometheus:
+		return writePrometheus(w, series)
+	default:
+		return writeJSON(w, series)
+	}
+}
+
+// writeJSON encodes series as {"name": {"date": value, ...}, ...}, matching
+// the flat date-keyed map shape WeightInsiderData already uses in data.json.
+func writeJSON(w http.ResponseWriter, series []Series) error {
+	out := make(map[string]map[string]float64, len(series))
+	for _, s := range series {
+		out[s.Name] = s.Points
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(out)
+}
+
+func writeCSV(w http.ResponseWriter, series []Series) error {
+	w.Header().Set("Content-Type", "text/csv")
+	writer := csv.NewWriter(w)
+	defer writer.Flush()
+
+	if err := writer.Write([]string{"series", "date", "value"}); err != nil {
+		return err
+	}
+	for _, s := range series {
+		for _, date := range sortedDates(s.Points) {
+			row := []string{s.Name, date, strconv.FormatFloat(s.Points[date], 'f', -1, 64)}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	return writer.Error()
+}
+
+var prometheusNameRe = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+func writePrometheus(w http.ResponseWriter, series []Series) error {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, s := range series {
+		metric := "weight_insider_" + prometheusNameRe.ReplaceAllString(s.Name, "_")
+		if _, err := fmt.Fprintf(w, "# TYPE %s gauge\n", metric); err != nil {
+			return err
+		}
+		for _, date := range sortedDates(s.Points) {
+			if _, err := fmt.Fprintf(w, "%s{date=%q} %v\n", metric, date, s.Points[date]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func sortedDates(points map[string]float64) []string {
+	dates := make([]string, 0, len(points))
+	for date := range points {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	return dates
+}
+
+// RequireBearer wraps next so it only runs when the request carries
+// "Authorization: Bearer <token>" matching token. An empty token always
+// rejects, so writes can't accidentally be left open by a missing config
+// value.
+func RequireBearer(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
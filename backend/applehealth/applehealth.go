@@ -0,0 +1,110 @@
+// Package applehealth parses the export.xml produced by the iOS Health
+// app's "Export All Health Data" action, reading it as a local, credential-
+// free alternative to the API-backed sources.
+package applehealth
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	bodyMassType          = "HKQuantityTypeIdentifierBodyMass"
+	bodyFatPercentageType = "HKQuantityTypeIdentifierBodyFatPercentage"
+)
+
+// record mirrors the attributes we care about on a Health export <Record>
+// element; every other attribute (device, source name, ...) is ignored.
+type record struct {
+	Type      string `xml:"type,attr"`
+	Unit      string `xml:"unit,attr"`
+	Value     string `xml:"value,attr"`
+	StartDate string `xml:"startDate,attr"`
+}
+
+// Export holds the per-day values we extract from export.xml. Apple Health
+// can log several samples a day (e.g. a smart scale syncing repeatedly);
+// the last sample for a day wins, same as every other source in this repo
+// that only wants one value per day.
+type Export struct {
+	Weights map[string]float64
+	BodyFat map[string]float64
+}
+
+// Parse reads and streams the Health export.xml at path. The file is
+// usually tens to hundreds of MB, so it's decoded one <Record> at a time
+// rather than loaded into memory whole.
+func Parse(path string) (*Export, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %w", path, err)
+	}
+	defer file.Close()
+
+	export := &Export{
+		Weights: make(map[string]float64),
+		BodyFat: make(map[string]float64),
+	}
+
+	decoder := xml.NewDecoder(file)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", path, err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "Record" {
+			continue
+		}
+
+		var r record
+		if err := decoder.DecodeElement(&r, &start); err != nil {
+			return nil, fmt.Errorf("error decoding record in %s: %w", path, err)
+		}
+
+		if r.Type != bodyMassType && r.Type != bodyFatPercentageType {
+			continue
+		}
+
+		date, err := recordDate(r.StartDate)
+		if err != nil {
+			continue
+		}
+		value, err := strconv.ParseFloat(r.Value, 64)
+		if err != nil {
+			continue
+		}
+
+		switch r.Type {
+		case bodyMassType:
+			export.Weights[date] = valueInKilograms(value, r.Unit)
+		case bodyFatPercentageType:
+			export.BodyFat[date] = value * 100 // Health records this as a 0-1 fraction
+		}
+	}
+
+	return export, nil
+}
+
+func recordDate(startDate string) (string, error) {
+	t, err := time.Parse("2006-01-02 15:04:05 -0700", startDate)
+	if err != nil {
+		return "", fmt.Errorf("error parsing startDate %q: %w", startDate, err)
+	}
+	return t.Format("2006-01-02"), nil
+}
+
+func valueInKilograms(value float64, unit string) float64 {
+	if unit == "lb" {
+		return value * 0.45359237
+	}
+	return value
+}
@@ -2,20 +2,28 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"net/http/httputil"
 	"os"
 	"strings"
 	"sync"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/tomaszkaliciak/weight-insider/backend/fetcher"
+	"github.com/tomaszkaliciak/weight-insider/backend/fitbit"
+	"github.com/tomaszkaliciak/weight-insider/backend/gfitwriter"
+	"github.com/tomaszkaliciak/weight-insider/backend/googlefit"
+	"github.com/tomaszkaliciak/weight-insider/backend/withings"
 )
 
 const (
@@ -25,13 +33,87 @@ const (
 	ContentType      = "application/json;charset=UTF-8"
 	CredentialsFile  = "credentials.json"
 	DataJSONPath     = "../frontend/data.json"
+	SourcesFile      = "sources.json"
 )
 
+// defaultTrustedAppInfoIDs is used when sources.json is absent: app_info_id
+// 1 is com.google.android.apps.fitness, the only provider we trusted before
+// this became configurable.
+var defaultTrustedAppInfoIDs = []int64{1}
+
 type WeightInsiderData struct {
 	BodyFat              map[string]float64 `json:"bodyFat"`
 	CalorieIntake        map[string]int     `json:"calorieIntake"`
 	GoogleFitExpenditure map[string]int     `json:"googleFitExpenditure"`
+	FitbitExpenditure    map[string]int     `json:"fitbitExpenditure"`
 	Weights              map[string]float64 `json:"weights"`
+	Steps                map[string]int     `json:"steps"`
+	DistanceMeters       map[string]float64 `json:"distanceMeters"`
+	RestingHR            map[string]int     `json:"restingHR"`
+	SleepMinutes         map[string]int     `json:"sleepMinutes"`
+	// History keeps whatever a date's Weights entry held before it was
+	// overwritten by a different value, so a correction doesn't silently
+	// destroy the earlier reading.
+	History map[string][]WeightHistoryEntry `json:"history"`
+}
+
+// WeightHistoryEntry is one superseded weight reading for a date.
+type WeightHistoryEntry struct {
+	Value      float64   `json:"value"`
+	RecordedAt time.Time `json:"recordedAt"`
+}
+
+// AppleHealthSourceConfig points at a local Health app export.xml; unlike
+// the other sources it needs no credentials.
+type AppleHealthSourceConfig struct {
+	Enabled    bool   `json:"enabled"`
+	ExportPath string `json:"exportPath"`
+}
+
+// GoogleFitSourceConfig enables pulling weight and expenditure straight
+// from the Google Fit API instead of (or alongside) a Health Connect
+// export.
+type GoogleFitSourceConfig struct {
+	Enabled bool `json:"enabled"`
+	Days    int  `json:"days"`
+}
+
+// WithingsSourceConfig enables pulling weight and body fat from a user's
+// Withings smart scale.
+type WithingsSourceConfig struct {
+	Enabled bool `json:"enabled"`
+	Days    int  `json:"days"`
+}
+
+// SourcesConfig is the sources.json file: it controls which Health Connect
+// app_info_id values are trusted, in priority order, when the same day's
+// data is reported by more than one app, and which optional sources beyond
+// Fitatu/Health Connect/Fitbit are enabled.
+type SourcesConfig struct {
+	TrustedAppInfoIDs []int64                 `json:"trustedAppInfoIDs"`
+	AppleHealth       AppleHealthSourceConfig `json:"appleHealth"`
+	GoogleFit         GoogleFitSourceConfig   `json:"googleFit"`
+	Withings          WithingsSourceConfig    `json:"withings"`
+}
+
+// loadSourcesConfig reads filename, falling back to defaultTrustedAppInfoIDs
+// if it doesn't exist so a fresh checkout keeps working without one.
+func loadSourcesConfig(filename string) (*SourcesConfig, error) {
+	byteValue, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return &SourcesConfig{TrustedAppInfoIDs: defaultTrustedAppInfoIDs}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", filename, err)
+	}
+
+	var config SourcesConfig
+	if err := json.Unmarshal(byteValue, &config); err != nil {
+		return nil, fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	if len(config.TrustedAppInfoIDs) == 0 {
+		config.TrustedAppInfoIDs = defaultTrustedAppInfoIDs
+	}
+	return &config, nil
 }
 
 type WeightData struct {
@@ -41,8 +123,11 @@ type WeightData struct {
 }
 
 type Credentials struct {
-	Login    string `json:"login"`
-	Password string `json:"password"`
+	Login     string `json:"login"`
+	Password  string `json:"password"`
+	APIToken  string `json:"apiToken"`
+	JWKSURL   string `json:"jwksUrl"`
+	JWTSecret string `json:"jwtSecret"`
 }
 
 type JWT struct {
@@ -76,14 +161,22 @@ type TotalCaloriesBurnedRecord struct {
 	LocalDateTimeEndTime   sql.NullInt64
 }
 
+// decodeBase64 decodes a JWT segment, which is base64url without padding,
+// tolerating the handful of other encodings (padded url-safe, standard)
+// seen in the wild.
 func decodeBase64(s string) ([]byte, error) {
-	missing := len(s) % 4
-	if missing != 0 {
-		s += strings.Repeat("=", 4-missing)
+	if decoded, err := base64.RawURLEncoding.DecodeString(s); err == nil {
+		return decoded, nil
+	}
+
+	padded := s
+	if missing := len(s) % 4; missing != 0 {
+		padded += strings.Repeat("=", 4-missing)
 	}
-	decoded, err := base64.RawURLEncoding.DecodeString(s)
+
+	decoded, err := base64.URLEncoding.DecodeString(padded)
 	if err != nil {
-		decoded, err = base64.StdEncoding.DecodeString(s)
+		decoded, err = base64.StdEncoding.DecodeString(padded)
 		if err != nil {
 			return nil, fmt.Errorf("failed to decode base64 string: %w", err)
 		}
@@ -140,7 +233,7 @@ func loadCredentials(filename string) (*Credentials, error) {
 	return &credentials, nil
 }
 
-func makeHTTPRequest(client *http.Client, method, url string, body io.Reader, headers map[string]string) (*http.Response, error) {
+func makeHTTPRequest(pool *fetcher.Pool, method, url string, body io.Reader, headers map[string]string) (*http.Response, error) {
 	req, err := http.NewRequest(method, url, body)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
@@ -149,20 +242,19 @@ func makeHTTPRequest(client *http.Client, method, url string, body io.Reader, he
 		req.Header.Set(key, value)
 	}
 
-	requestDump, _ := httputil.DumpRequestOut(req, true)
-	fmt.Printf("Request:\n%s\n", string(requestDump))
-
-	resp, err := client.Do(req)
+	resp, err := pool.Do(context.Background(), req)
 	if err != nil {
 		return nil, fmt.Errorf("error sending request: %w", err)
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed with status code: %d", resp.StatusCode)
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, parseAPIError(resp.StatusCode, respBody)
 	}
 	return resp, nil
 }
 
-func fitatuLogin(client *http.Client, credentials *Credentials) (string, string, error) {
+func fitatuLogin(pool *fetcher.Pool, credentials *Credentials) (string, string, error) {
 	values := map[string]string{"_username": credentials.Login, "_password": credentials.Password}
 	jsonValue, err := json.Marshal(values)
 	if err != nil {
@@ -175,7 +267,7 @@ func fitatuLogin(client *http.Client, credentials *Credentials) (string, string,
 		"content-Type": ContentType,
 	}
 
-	resp, err := makeHTTPRequest(client, "POST", FitatuAPIBaseURL+"/login", bytes.NewBuffer(jsonValue), headers)
+	resp, err := makeHTTPRequest(pool, "POST", FitatuAPIBaseURL+"/login", bytes.NewBuffer(jsonValue), headers)
 	if err != nil {
 		return "", "", err
 	}
@@ -201,11 +293,95 @@ func fitatuLogin(client *http.Client, credentials *Credentials) (string, string,
 		refreshToken = ""
 	}
 
+	if _, err := verifyFitatuToken(token, credentials); err != nil {
+		return "", "", fmt.Errorf("token verification failed: %w", err)
+	}
+
 	return token, refreshToken, nil
 }
 
-func fetchWeightData(client *http.Client, userID, token string) (*WeightData, error) {
+// fitatuRefresh exchanges a refresh token (returned alongside the bearer
+// token by fitatuLogin) for a new token pair, so callers hitting 401 don't
+// need to fall back to a full re-login. The new token is verified just like
+// a freshly logged-in one, so a tampered or expired token is rejected
+// immediately instead of being handed out by TokenManager.
+func fitatuRefresh(pool *fetcher.Pool, refreshToken string, credentials *Credentials) (string, string, error) {
+	values := map[string]string{"refresh_token": refreshToken}
+	jsonValue, err := json.Marshal(values)
+	if err != nil {
+		return "", "", fmt.Errorf("error marshalling JSON: %w", err)
+	}
+
+	headers := map[string]string{
+		"api-secret":   ApiSecret,
+		"api-key":      ApiKey,
+		"content-Type": ContentType,
+	}
+
+	resp, err := makeHTTPRequest(pool, "POST", FitatuAPIBaseURL+"/token/refresh", bytes.NewBuffer(jsonValue), headers)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", "", fmt.Errorf("error reading response: %w", err)
+	}
+
+	var responseData map[string]any
+	if err := json.Unmarshal(body, &responseData); err != nil {
+		return "", "", fmt.Errorf("error unmarshaling JSON: %w", err)
+	}
+
+	token, ok := responseData["token"].(string)
+	if !ok {
+		return "", "", fmt.Errorf("token not found or not a string")
+	}
+
+	newRefreshToken, ok := responseData["refresh_token"].(string)
+	if !ok {
+		newRefreshToken = refreshToken
+	}
+
+	if _, err := verifyFitatuToken(token, credentials); err != nil {
+		return "", "", fmt.Errorf("token verification failed: %w", err)
+	}
+
+	return token, newRefreshToken, nil
+}
+
+// fetchWeightDataRange fetches the weight chart for userID restricted to
+// [from, to) (either may be zero for an open end), using tokens to get a
+// valid bearer and invalidating it for one retry if the API still rejects
+// the token as expired.
+func fetchWeightDataRange(pool *fetcher.Pool, userID string, tokens *TokenManager, from, to time.Time) (*WeightData, error) {
+	token, err := tokens.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	weightData, err := doFetchWeightData(pool, userID, token, from, to)
+	if err == nil {
+		return weightData, nil
+	}
+	if !isUnauthorized(err) {
+		return nil, err
+	}
+
+	tokens.Invalidate()
+	token, err = tokens.Token()
+	if err != nil {
+		return nil, fmt.Errorf("token expired and refresh failed: %w", err)
+	}
+	return doFetchWeightData(pool, userID, token, from, to)
+}
+
+func doFetchWeightData(pool *fetcher.Pool, userID, token string, from, to time.Time) (*WeightData, error) {
 	url := fmt.Sprintf("%s/users/%s/measurements/chart/weight", FitatuAPIBaseURL, userID)
+	if query := weightRangeQuery(from, to); query != "" {
+		url += "?" + query
+	}
 	headers := map[string]string{
 		"api-secret":    ApiSecret,
 		"api-key":       ApiKey,
@@ -213,7 +389,7 @@ func fetchWeightData(client *http.Client, userID, token string) (*WeightData, er
 		"authorization": "Bearer " + token,
 	}
 
-	resp, err := makeHTTPRequest(client, "GET", url, nil, headers)
+	resp, err := makeHTTPRequest(pool, "GET", url, nil, headers)
 	if err != nil {
 		return nil, err
 	}
@@ -231,7 +407,48 @@ func fetchWeightData(client *http.Client, userID, token string) (*WeightData, er
 	return &weightData, nil
 }
 
-func fetchintakeData(client *http.Client, userID, token string, dateToCheck time.Time) (*PlanData, error) {
+func weightRangeQuery(from, to time.Time) string {
+	params := make([]string, 0, 2)
+	if !from.IsZero() {
+		params = append(params, "from="+from.Format("2006-01-02"))
+	}
+	if !to.IsZero() {
+		params = append(params, "to="+to.Format("2006-01-02"))
+	}
+	return strings.Join(params, "&")
+}
+
+func isUnauthorized(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized
+}
+
+// fetchintakeData fetches the diet plan for dateToCheck, using tokens to
+// get a valid bearer and invalidating it for one retry if the API still
+// rejects the token as expired.
+func fetchintakeData(pool *fetcher.Pool, userID string, tokens *TokenManager, dateToCheck time.Time) (*PlanData, error) {
+	token, err := tokens.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	planData, err := doFetchIntakeData(pool, userID, token, dateToCheck)
+	if err == nil {
+		return planData, nil
+	}
+	if !isUnauthorized(err) {
+		return nil, err
+	}
+
+	tokens.Invalidate()
+	token, err = tokens.Token()
+	if err != nil {
+		return nil, fmt.Errorf("token expired and refresh failed: %w", err)
+	}
+	return doFetchIntakeData(pool, userID, token, dateToCheck)
+}
+
+func doFetchIntakeData(pool *fetcher.Pool, userID, token string, dateToCheck time.Time) (*PlanData, error) {
 	url := fmt.Sprintf("%s/diet-and-activity-plan/%s/day/%s", FitatuAPIBaseURL, userID, dateToCheck.Format("2006-01-02"))
 
 	headers := map[string]string{
@@ -241,7 +458,7 @@ func fetchintakeData(client *http.Client, userID, token string, dateToCheck time
 		"authorization": "Bearer " + token,
 	}
 
-	resp, err := makeHTTPRequest(client, "GET", url, nil, headers)
+	resp, err := makeHTTPRequest(pool, "GET", url, nil, headers)
 	if err != nil {
 		return nil, err
 	}
@@ -279,30 +496,151 @@ func fetchTotalCaloriesBurnedRecords(db *sql.DB) ([]TotalCaloriesBurnedRecord, e
 	return records, rows.Err()
 }
 
-func getCaloriesBurnedRecords(records []TotalCaloriesBurnedRecord) map[string]float64 {
-	fmt.Println("\n--- Total Calories Burned Records ---")
-	data := make(map[string]float64)
+func getCaloriesBurnedRecords(records []TotalCaloriesBurnedRecord, trustedAppInfoIDs []int64) map[string]float64 {
+	byDayAndApp := make(map[string]map[int64]float64)
 
 	for _, r := range records {
 		energy := r.Energy.Float64 / 1000
 		unixTimestamp := time.Unix(r.LocalDateTimeStartTime.Int64/1000, 0)
-
 		date := unixTimestamp.Format("2006-01-02")
 
-		// we trust appinfoid 1 (com.google.android.apps.fitness) for now to avoid dealing with duplicates between apps
-		if r.AppInfoID.Int64 == 1 {
-			data[date] += energy
+		addToDayAndApp(byDayAndApp, date, r.AppInfoID.Int64, energy)
+	}
+	return pickHighestPriorityApp(byDayAndApp, trustedAppInfoIDs)
+}
+
+// addToDayAndApp accumulates value into byDayAndApp[date][appInfoID], the
+// shared shape every per-day Health Connect aggregator groups records into
+// before dedupe picks a winning app.
+func addToDayAndApp(byDayAndApp map[string]map[int64]float64, date string, appInfoID int64, value float64) {
+	if byDayAndApp[date] == nil {
+		byDayAndApp[date] = make(map[int64]float64)
+	}
+	byDayAndApp[date][appInfoID] += value
+}
+
+// pickHighestPriorityApp resolves one value per day out of data reported by
+// multiple overlapping providers, mirroring how the gfit sync reconciles
+// activity segments: rather than summing every app's numbers together (which
+// double-counts the same steps/distance/sleep reported by two apps), it
+// takes the first trusted app (in priority order) that reported anything
+// for that day.
+func pickHighestPriorityApp(byDayAndApp map[string]map[int64]float64, trustedAppInfoIDs []int64) map[string]float64 {
+	result := make(map[string]float64)
+	for date, byApp := range byDayAndApp {
+		for _, appInfoID := range trustedAppInfoIDs {
+			if value, ok := byApp[appInfoID]; ok {
+				result[date] = value
+				break
+			}
 		}
 	}
-	return data
+	return result
 }
 
+func getStepsRecords(records []StepsRecord, trustedAppInfoIDs []int64) map[string]int {
+	byDayAndApp := make(map[string]map[int64]float64)
+
+	for _, r := range records {
+		if !r.StartTime.Valid || !r.Count.Valid {
+			continue
+		}
+		date := time.Unix(r.StartTime.Int64/1000, 0).Format("2006-01-02")
+		addToDayAndApp(byDayAndApp, date, r.AppInfoID.Int64, float64(r.Count.Int64))
+	}
+
+	steps := make(map[string]int)
+	for date, count := range pickHighestPriorityApp(byDayAndApp, trustedAppInfoIDs) {
+		steps[date] = int(count)
+	}
+	return steps
+}
+
+func getDistanceRecords(records []DistanceRecord, trustedAppInfoIDs []int64) map[string]float64 {
+	byDayAndApp := make(map[string]map[int64]float64)
+
+	for _, r := range records {
+		if !r.StartTime.Valid || !r.Distance.Valid {
+			continue
+		}
+		date := time.Unix(r.StartTime.Int64/1000, 0).Format("2006-01-02")
+		addToDayAndApp(byDayAndApp, date, r.AppInfoID.Int64, r.Distance.Float64)
+	}
+
+	return pickHighestPriorityApp(byDayAndApp, trustedAppInfoIDs)
+}
+
+// getRestingHRRecords takes the lowest BPM sample seen each day as a proxy
+// for resting heart rate, per app, then dedupes across apps the same way
+// the other aggregators do.
+func getRestingHRRecords(records []HeartRateSample, trustedAppInfoIDs []int64) map[string]int {
+	byDayAndApp := make(map[string]map[int64]int64)
+
+	for _, r := range records {
+		date := time.Unix(r.EpochMillis/1000, 0).Format("2006-01-02")
+		appInfoID := r.AppInfoID.Int64
+
+		if byDayAndApp[date] == nil {
+			byDayAndApp[date] = make(map[int64]int64)
+		}
+		if lowest, ok := byDayAndApp[date][appInfoID]; !ok || r.BeatsPerMinute < lowest {
+			byDayAndApp[date][appInfoID] = r.BeatsPerMinute
+		}
+	}
+
+	byDayAndAppFloat := make(map[string]map[int64]float64)
+	for date, byApp := range byDayAndApp {
+		byDayAndAppFloat[date] = make(map[int64]float64)
+		for appInfoID, bpm := range byApp {
+			byDayAndAppFloat[date][appInfoID] = float64(bpm)
+		}
+	}
+
+	restingHR := make(map[string]int)
+	for date, bpm := range pickHighestPriorityApp(byDayAndAppFloat, trustedAppInfoIDs) {
+		restingHR[date] = int(bpm)
+	}
+	return restingHR
+}
+
+func getSleepMinutesRecords(records []SleepSessionRecord, trustedAppInfoIDs []int64) map[string]int {
+	byDayAndApp := make(map[string]map[int64]float64)
+
+	for _, r := range records {
+		if !r.StartTime.Valid || !r.EndTime.Valid {
+			continue
+		}
+		date := time.Unix(r.StartTime.Int64/1000, 0).Format("2006-01-02")
+		minutes := float64(r.EndTime.Int64-r.StartTime.Int64) / 60000
+		addToDayAndApp(byDayAndApp, date, r.AppInfoID.Int64, minutes)
+	}
+
+	sleepMinutes := make(map[string]int)
+	for date, minutes := range pickHighestPriorityApp(byDayAndApp, trustedAppInfoIDs) {
+		sleepMinutes[date] = int(minutes)
+	}
+	return sleepMinutes
+}
+
+// dataFileMu serializes every load-mutate-save cycle against DataJSONPath.
+// The webhook server's workers and the API server's handlers can both race
+// to read, merge, and overwrite the file from separate goroutines; callers
+// that mutate data between loadOrInitData and saveData must hold this for
+// the whole cycle.
+var dataFileMu sync.Mutex
+
 func loadOrInitData(filename string) (*WeightInsiderData, error) {
 	data := &WeightInsiderData{
 		BodyFat:              make(map[string]float64),
 		CalorieIntake:        make(map[string]int),
 		GoogleFitExpenditure: make(map[string]int),
+		FitbitExpenditure:    make(map[string]int),
 		Weights:              make(map[string]float64),
+		Steps:                make(map[string]int),
+		DistanceMeters:       make(map[string]float64),
+		RestingHR:            make(map[string]int),
+		SleepMinutes:         make(map[string]int),
+		History:              make(map[string][]WeightHistoryEntry),
 	}
 
 	file, err := os.ReadFile(filename)
@@ -328,9 +666,27 @@ func loadOrInitData(filename string) (*WeightInsiderData, error) {
 	if data.GoogleFitExpenditure == nil {
 		data.GoogleFitExpenditure = make(map[string]int)
 	}
+	if data.FitbitExpenditure == nil {
+		data.FitbitExpenditure = make(map[string]int)
+	}
 	if data.Weights == nil {
 		data.Weights = make(map[string]float64)
 	}
+	if data.Steps == nil {
+		data.Steps = make(map[string]int)
+	}
+	if data.DistanceMeters == nil {
+		data.DistanceMeters = make(map[string]float64)
+	}
+	if data.RestingHR == nil {
+		data.RestingHR = make(map[string]int)
+	}
+	if data.SleepMinutes == nil {
+		data.SleepMinutes = make(map[string]int)
+	}
+	if data.History == nil {
+		data.History = make(map[string][]WeightHistoryEntry)
+	}
 
 	return data, nil
 }
@@ -347,102 +703,119 @@ func saveData(filename string, data *WeightInsiderData) error {
 	return nil
 }
 
-func main() {
-	client := &http.Client{}
+// daysOrDefault returns days, or 90 if it's unset, matching the lookback
+// window every other authed source defaults to.
+func daysOrDefault(days int) int {
+	if days <= 0 {
+		return 90
+	}
+	return days
+}
 
+// buildSources assembles every Source this install has credentials for and
+// has enabled in sources.json. Fitbit, Google Fit, and Withings are all
+// optional: a user can run the sync purely against whichever of them they
+// drop credentials for next to credentials.json.
+func buildSources() ([]Source, error) {
 	credentials, err := loadCredentials(CredentialsFile)
 	if err != nil {
-		log.Fatalf("Failed to load credentials: %v", err)
+		return nil, fmt.Errorf("failed to load credentials: %w", err)
 	}
 
-	token, _, err := fitatuLogin(client, credentials)
+	sourcesConfig, err := loadSourcesConfig(SourcesFile)
 	if err != nil {
-		log.Fatalf("Login failed: %v", err)
+		return nil, fmt.Errorf("failed to load sources config: %w", err)
 	}
-	fmt.Println("Login successful.")
 
-	jwtData, err := DecodeJWT(token)
-	if err != nil {
-		log.Fatalf("Error decoding JWT: %v", err)
+	pool := fetcher.NewPool(&http.Client{Transport: newLoggingTransport(nil)}, fetcher.DefaultConcurrency)
+	tokens := NewTokenManager(pool, credentials, TokenCacheFile)
+
+	sources := []Source{
+		&fitatuSource{pool: pool, tokens: tokens, syncStatePath: SyncStateFile},
+		&healthConnectSource{dbPath: "./health_connect_export.db", trustedAppInfoIDs: sourcesConfig.TrustedAppInfoIDs},
 	}
-	idValue, ok := jwtData.Payload["id"].(string)
-	if !ok {
-		log.Fatalf("JWT ID not found")
+
+	if fitbitClient, err := fitbit.NewClient(fitbit.CredentialsFile); err == nil {
+		sources = append(sources, &fitbitSource{client: fitbitClient, user: credentials.Login, days: 90})
+	} else {
+		log.Printf("Fitbit source disabled: %v", err)
 	}
 
-	fmt.Printf("Loading existing data from %s...\n", DataJSONPath)
-	insiderData, err := loadOrInitData(DataJSONPath)
-	if err != nil {
-		log.Fatalf("Failed to load existing data: %v", err)
+	if sourcesConfig.AppleHealth.Enabled {
+		sources = append(sources, &appleHealthSource{exportPath: sourcesConfig.AppleHealth.ExportPath})
 	}
 
-	fmt.Println("Fetching weight data...")
-	weightData, err := fetchWeightData(client, idValue, token)
-	if err != nil {
-		log.Printf("Failed to fetch weight data: %v", err)
-	} else {
-		for date, weight := range weightData.Weights {
-			insiderData.Weights[date] = weight
+	if sourcesConfig.GoogleFit.Enabled {
+		if googleFitClient, err := googlefit.NewClient(googlefit.CredentialsFile); err == nil {
+			sources = append(sources, &googleFitSource{client: googleFitClient, user: credentials.Login, days: daysOrDefault(sourcesConfig.GoogleFit.Days)})
+		} else {
+			log.Printf("Google Fit source disabled: %v", err)
 		}
-		fmt.Printf("Updated %d weight records.\n", len(weightData.Weights))
 	}
 
-	fmt.Println("Fetching intake data (last 90 days)...")
-	now := time.Now().UTC()
-	numRequests := 90
+	if sourcesConfig.Withings.Enabled {
+		if withingsClient, err := withings.NewClient(withings.CredentialsFile); err == nil {
+			sources = append(sources, &withingsSource{client: withingsClient, user: credentials.Login, days: daysOrDefault(sourcesConfig.Withings.Days)})
+		} else {
+			log.Printf("Withings source disabled: %v", err)
+		}
+	}
 
-	var wg sync.WaitGroup
-	wg.Add(numRequests)
-	results := make(chan PlanDataDay, numRequests)
+	return sources, nil
+}
 
-	for i := 0; i < numRequests; i++ {
-		go func(dayOffset int) {
-			defer wg.Done()
-			dateToCheck := now.AddDate(0, 0, -dayOffset)
-			intakeData, err := fetchintakeData(client, idValue, token, dateToCheck)
-			if err == nil {
-				results <- PlanDataDay{planData: *intakeData, calendarDay: dateToCheck}
-			}
-		}(i)
+// calorieBalance computes CalorieIntake minus GoogleFitExpenditure for every
+// date we have an intake figure for, for writing back to Google Fit via
+// gfitwriter.
+func calorieBalance(data *WeightInsiderData) map[string]int {
+	balance := make(map[string]int, len(data.CalorieIntake))
+	for date, intake := range data.CalorieIntake {
+		balance[date] = intake - data.GoogleFitExpenditure[date]
 	}
+	return balance
+}
 
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
-	countIntake := 0
-	for result := range results {
-		sum := 0.0
-		for _, value := range result.planData.DietPlan {
-			for _, element := range value.Items {
-				sum += element.Energy
-			}
+func main() {
+	webhookAddr := flag.String("webhook", "", "if set, run an HTTP server on this address receiving push notifications instead of doing a one-shot sync")
+	apiAddr := flag.String("server", "", "if set, run an HTTP server on this address exposing the collected data as a REST API instead of doing a one-shot sync")
+	gfitDryRun := flag.Bool("dry-run", false, "log the calorie-balance writes gfitwriter would make to Google Fit instead of making them")
+	flag.Parse()
+
+	if *webhookAddr != "" {
+		if err := runWebhookServer(*webhookAddr); err != nil {
+			log.Fatalf("webhook server failed: %v", err)
 		}
-		if sum > 0 {
-			dateKey := result.calendarDay.Format("2006-01-02")
-			insiderData.CalorieIntake[dateKey] = int(sum)
-			countIntake++
+		return
+	}
+
+	if *apiAddr != "" {
+		if err := runAPIServer(*apiAddr); err != nil {
+			log.Fatalf("api server failed: %v", err)
 		}
+		return
 	}
-	fmt.Printf("Updated %d intake records.\n", countIntake)
 
-	fmt.Println("Fetching expenditure from DB...")
-	db, err := sql.Open("sqlite3", "./health_connect_export.db")
+	ctx := context.Background()
+
+	sources, err := buildSources()
 	if err != nil {
-		log.Printf("Failed to open database: %v", err)
-	} else {
-		caloriesBurnedRecords, err := fetchTotalCaloriesBurnedRecords(db)
+		log.Fatalf("%v", err)
+	}
+
+	fmt.Printf("Loading existing data from %s...\n", DataJSONPath)
+	insiderData, err := loadOrInitData(DataJSONPath)
+	if err != nil {
+		log.Fatalf("Failed to load existing data: %v", err)
+	}
+
+	for _, source := range sources {
+		fmt.Printf("Fetching from %s...\n", source.Name())
+		result, err := source.Fetch(ctx)
 		if err != nil {
-			log.Printf("Could not fetch total calories burned records: %v", err)
-		} else {
-			expenditureRecords := getCaloriesBurnedRecords(caloriesBurnedRecords)
-			for date, kcal := range expenditureRecords {
-				insiderData.GoogleFitExpenditure[date] = int(kcal)
-			}
-			fmt.Printf("Updated %d expenditure records.\n", len(expenditureRecords))
+			log.Printf("Source %s failed: %v", source.Name(), err)
+			continue
 		}
-		db.Close()
+		mergeSourceResult(insiderData, result)
 	}
 
 	fmt.Printf("Saving all data to %s...\n", DataJSONPath)
@@ -450,4 +823,20 @@ func main() {
 		log.Fatalf("Failed to save data.json: %v", err)
 	}
 	fmt.Println("Success! All data updated and valid JSON saved.")
+
+	credentials, err := loadCredentials(CredentialsFile)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	writer, err := gfitwriter.NewWriter(gfitwriter.CredentialsFile, *gfitDryRun)
+	if err != nil {
+		log.Printf("Google Fit balance writer disabled: %v", err)
+		return
+	}
+
+	fmt.Println("Writing derived calorie balance to Google Fit...")
+	if err := writer.WriteDailyBalance(ctx, credentials.Login, calorieBalance(insiderData)); err != nil {
+		log.Printf("Failed to write calorie balance to Google Fit: %v", err)
+	}
 }
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tomaszkaliciak/weight-insider/backend/fetcher"
+)
+
+const (
+	TokenCacheFile   = "fitatu_tokens.json"
+	DefaultTokenSkew = 60 * time.Second
+)
+
+// tokenCache is the on-disk shape TokenManager persists next to
+// credentials.json, so a restart doesn't force a fresh login.
+type tokenCache struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// TokenManager keeps a Fitatu bearer token valid across calls: it caches the
+// token pair on disk, checks the decoded JWT's exp claim before handing the
+// token out, and refreshes (or, failing that, logs in fresh) whenever the
+// token is within skew of expiring.
+type TokenManager struct {
+	pool        *fetcher.Pool
+	credentials *Credentials
+	path        string
+	skew        time.Duration
+
+	mu           sync.Mutex
+	token        string
+	refreshToken string
+	loaded       bool
+}
+
+// NewTokenManager returns a TokenManager that persists its cache to path and
+// refreshes tokens within DefaultTokenSkew of expiring.
+func NewTokenManager(pool *fetcher.Pool, credentials *Credentials, path string) *TokenManager {
+	return &TokenManager{pool: pool, credentials: credentials, path: path, skew: DefaultTokenSkew}
+}
+
+func (m *TokenManager) loadFromDisk() {
+	m.loaded = true
+
+	raw, err := os.ReadFile(m.path)
+	if err != nil {
+		return
+	}
+	var cache tokenCache
+	if err := json.Unmarshal(raw, &cache); err != nil {
+		return
+	}
+	m.token = cache.Token
+	m.refreshToken = cache.RefreshToken
+}
+
+func (m *TokenManager) persist() error {
+	raw, err := json.MarshalIndent(tokenCache{Token: m.token, RefreshToken: m.refreshToken}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling token cache: %w", err)
+	}
+	return os.WriteFile(m.path, raw, 0600)
+}
+
+// expiringSoon reports whether token has no readable exp claim or expires
+// within skew, treating anything undecodable as expired so we err on the
+// side of refreshing.
+func (m *TokenManager) expiringSoon(token string) bool {
+	jwt, err := DecodeJWT(token)
+	if err != nil {
+		return true
+	}
+	exp, ok := jwt.Payload["exp"].(float64)
+	if !ok {
+		return true
+	}
+	return time.Until(time.Unix(int64(exp), 0)) <= m.skew
+}
+
+// Token returns a bearer token that is valid for at least skew, logging in
+// or refreshing as needed.
+func (m *TokenManager) Token() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.loaded {
+		m.loadFromDisk()
+	}
+
+	if m.token != "" && !m.expiringSoon(m.token) {
+		return m.token, nil
+	}
+
+	if m.refreshToken != "" {
+		if token, refreshToken, err := fitatuRefresh(m.pool, m.refreshToken, m.credentials); err == nil {
+			m.token, m.refreshToken = token, refreshToken
+			if err := m.persist(); err != nil {
+				return "", err
+			}
+			return m.token, nil
+		}
+	}
+
+	token, refreshToken, err := fitatuLogin(m.pool, m.credentials)
+	if err != nil {
+		return "", err
+	}
+	m.token, m.refreshToken = token, refreshToken
+	if err := m.persist(); err != nil {
+		return "", err
+	}
+	return m.token, nil
+}
+
+// Invalidate forces the next Token call to refresh or re-login, for when a
+// call fails with 401 despite Token's own exp check (clock skew, an
+// out-of-band revocation, etc).
+func (m *TokenManager) Invalidate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.token = ""
+}
+
+// UserID returns the Fitatu user id embedded in the current token's JWT
+// payload.
+func (m *TokenManager) UserID() (string, error) {
+	token, err := m.Token()
+	if err != nil {
+		return "", err
+	}
+	jwtData, err := DecodeJWT(token)
+	if err != nil {
+		return "", fmt.Errorf("error decoding JWT: %w", err)
+	}
+	userID, ok := jwtData.Payload["id"].(string)
+	if !ok {
+		return "", fmt.Errorf("JWT ID not found")
+	}
+	return userID, nil
+}
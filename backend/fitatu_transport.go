@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// redactedHeaders lists the request headers whose values must never reach
+// logs verbatim.
+var redactedHeaders = map[string]bool{
+	"authorization": true,
+	"api-secret":    true,
+}
+
+// redactedBodyFields lists JSON request body keys to mask before logging,
+// e.g. the plaintext password fitatuLogin sends.
+var redactedBodyFields = map[string]bool{
+	"_password": true,
+}
+
+const redactedPlaceholder = "[REDACTED]"
+
+// loggingTransport wraps an http.RoundTripper with structured, redacted
+// request/response logging, replacing the old raw httputil.DumpRequestOut
+// dump (which printed api-secret and the login password to stdout).
+type loggingTransport struct {
+	next   http.RoundTripper
+	logger *slog.Logger
+}
+
+// newLoggingTransport returns a loggingTransport wrapping next (or
+// http.DefaultTransport if nil).
+func newLoggingTransport(next http.RoundTripper) *loggingTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &loggingTransport{next: next, logger: slog.Default()}
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.logger.Debug("http request", "method", req.Method, "url", req.URL.String(), "headers", redactHeaders(req.Header), "body", t.redactedRequestBody(req))
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start)
+
+	if err != nil {
+		t.logger.Warn("http request failed", "method", req.Method, "url", req.URL.String(), "error", err, "elapsed", elapsed)
+		return nil, err
+	}
+	t.logger.Debug("http response", "method", req.Method, "url", req.URL.String(), "status", resp.StatusCode, "elapsed", elapsed)
+	return resp, nil
+}
+
+func redactHeaders(headers http.Header) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for key, values := range headers {
+		value := strings.Join(values, ",")
+		if redactedHeaders[strings.ToLower(key)] {
+			value = redactedPlaceholder
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
+
+// redactedRequestBody returns req's JSON body with redactedBodyFields
+// masked, for logging only; it reads via GetBody so the real request body
+// sent over the wire is untouched.
+func (t *loggingTransport) redactedRequestBody(req *http.Request) string {
+	if req.GetBody == nil {
+		return ""
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+
+	raw, err := io.ReadAll(body)
+	if err != nil || len(raw) == 0 {
+		return ""
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return string(raw)
+	}
+	for key := range fields {
+		if redactedBodyFields[key] {
+			fields[key] = redactedPlaceholder
+		}
+	}
+	redacted, err := json.Marshal(fields)
+	if err != nil {
+		return string(raw)
+	}
+	return string(redacted)
+}
+
+// APIError is a parsed Fitatu error response, letting callers tell apart
+// "bad credentials", "rate limited", and "server down" instead of matching
+// on a bare status-code string.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("fitatu api error: status code: %d, code=%s, message=%s", e.StatusCode, e.Code, e.Message)
+}
+
+// fitatuErrorBody is the shape of Fitatu's JSON error responses.
+type fitatuErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// parseAPIError builds an APIError from a non-200 response body, falling
+// back to the raw body text as the message if it isn't the expected shape.
+func parseAPIError(statusCode int, body []byte) *APIError {
+	var parsed fitatuErrorBody
+	if err := json.Unmarshal(body, &parsed); err != nil || (parsed.Code == "" && parsed.Message == "") {
+		return &APIError{StatusCode: statusCode, Message: strings.TrimSpace(string(body))}
+	}
+	return &APIError{StatusCode: statusCode, Code: parsed.Code, Message: parsed.Message}
+}
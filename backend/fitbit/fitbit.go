@@ -0,0 +1,301 @@
+// Package fitbit implements a minimal OAuth2 client for Fitbit's Web API,
+// used as an alternative to the Health Connect SQLite export for calorie
+// expenditure, steps, heart rate, and sleep data.
+package fitbit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tomaszkaliciak/weight-insider/backend/fetcher"
+	"golang.org/x/oauth2"
+)
+
+const (
+	APIBaseURL      = "https://api.fitbit.com/1/user/-"
+	TokensFile      = "fitbit_tokens.json"
+	CredentialsFile = "fitbit_credentials.json"
+)
+
+var Endpoint = oauth2.Endpoint{
+	AuthURL:  "https://www.fitbit.com/oauth2/authorize",
+	TokenURL: "https://api.fitbit.com/oauth2/token",
+}
+
+// Credentials holds the Fitbit app registration, loaded from CredentialsFile
+// next to the Fitatu credentials.json.
+type Credentials struct {
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	RedirectURL  string `json:"redirectUrl"`
+}
+
+func loadCredentials(filename string) (*Credentials, error) {
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", filename, err)
+	}
+	var creds Credentials
+	if err := json.Unmarshal(raw, &creds); err != nil {
+		return nil, fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	return &creds, nil
+}
+
+// Client drives the 3-legged OAuth dance and fetches daily summaries for a
+// single Fitbit user.
+type Client struct {
+	config *oauth2.Config
+	tokens *tokenStore
+	pool   *fetcher.Pool
+}
+
+func NewClient(credentialsFile string) (*Client, error) {
+	creds, err := loadCredentials(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &oauth2.Config{
+		ClientID:     creds.ClientID,
+		ClientSecret: creds.ClientSecret,
+		RedirectURL:  creds.RedirectURL,
+		Endpoint:     Endpoint,
+		Scopes:       []string{"activity", "heartrate", "profile", "sleep"},
+	}
+
+	tokens, err := loadTokenStore(TokensFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{config: config, tokens: tokens, pool: fetcher.NewPool(nil, fetcher.DefaultConcurrency)}, nil
+}
+
+// csrfState returns a per-user HMAC-signed state parameter so the callback
+// handler can verify ParseToken requests weren't forged.
+func (c *Client) csrfState(user string) string {
+	mac := hmac.New(sha256.New, []byte(c.config.ClientSecret))
+	mac.Write([]byte(user))
+	return user + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c *Client) verifyState(state string) (user string, ok bool) {
+	idx := strings.LastIndex(state, ".")
+	if idx < 0 {
+		return "", false
+	}
+	user = state[:idx]
+	if !hmac.Equal([]byte(c.csrfState(user)), []byte(state)) {
+		return "", false
+	}
+	return user, true
+}
+
+// AuthURL returns the URL the user should be redirected to in order to grant
+// access to their Fitbit data.
+func (c *Client) AuthURL(user string) string {
+	return c.config.AuthCodeURL(c.csrfState(user))
+}
+
+// ParseToken handles the OAuth2 redirect callback, exchanging the
+// authorization code for a token and persisting it for user.
+func (c *Client) ParseToken(ctx context.Context, r *http.Request) (*oauth2.Token, error) {
+	query := r.URL.Query()
+	state := query.Get("state")
+	code := query.Get("code")
+
+	user, ok := c.verifyState(state)
+	if !ok {
+		return nil, fmt.Errorf("invalid or tampered CSRF state parameter")
+	}
+
+	token, err := c.config.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("error exchanging authorization code: %w", err)
+	}
+
+	if err := c.tokens.set(user, token); err != nil {
+		return nil, fmt.Errorf("error persisting token for %s: %w", user, err)
+	}
+
+	return token, nil
+}
+
+// accessToken returns a valid access token for user, transparently
+// refreshing and persisting the stored token when it's expired.
+func (c *Client) accessToken(ctx context.Context, user string) (string, error) {
+	token, ok := c.tokens.get(user)
+	if !ok {
+		return "", fmt.Errorf("no stored Fitbit token for user %s; visit AuthURL first", user)
+	}
+
+	src := c.config.TokenSource(ctx, token)
+	refreshed, err := src.Token()
+	if err != nil {
+		return "", fmt.Errorf("error refreshing Fitbit token: %w", err)
+	}
+	if refreshed.AccessToken != token.AccessToken {
+		if err := c.tokens.set(user, refreshed); err != nil {
+			return "", fmt.Errorf("error persisting refreshed token: %w", err)
+		}
+	}
+
+	return refreshed.AccessToken, nil
+}
+
+// getJSON fetches path through the bounded-concurrency, retrying pool
+// instead of a bare http.Client, so a day-by-day range fetch (see
+// ExpenditureRange) can't open unbounded sockets or abort on the first
+// rate-limited response.
+func (c *Client) getJSON(ctx context.Context, user, path string, out any) error {
+	token, err := c.accessToken(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	reqURL := APIBaseURL + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.pool.Do(ctx, req)
+	if err != nil {
+		return fmt.Errorf("error fetching %s: %w", reqURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fitbit request to %s failed with status code: %d", path, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type activitySummaryResponse struct {
+	Summary struct {
+		CaloriesOut int `json:"caloriesOut"`
+		Steps       int `json:"steps"`
+	} `json:"summary"`
+}
+
+// FetchActivitySummary returns calories burned and steps for date.
+func (c *Client) FetchActivitySummary(ctx context.Context, user string, date time.Time) (caloriesOut, steps int, err error) {
+	path := fmt.Sprintf("/activities/date/%s.json", date.Format("2006-01-02"))
+	var resp activitySummaryResponse
+	if err := c.getJSON(ctx, user, path, &resp); err != nil {
+		return 0, 0, err
+	}
+	return resp.Summary.CaloriesOut, resp.Summary.Steps, nil
+}
+
+type heartRateResponse struct {
+	ActivitiesHeart []struct {
+		Value struct {
+			RestingHeartRate int `json:"restingHeartRate"`
+		} `json:"value"`
+	} `json:"activities-heart"`
+}
+
+// FetchRestingHeartRate returns the resting heart rate reported for date, if any.
+func (c *Client) FetchRestingHeartRate(ctx context.Context, user string, date time.Time) (restingHR int, err error) {
+	path := fmt.Sprintf("/activities/heart/date/%s/1d.json", date.Format("2006-01-02"))
+	var resp heartRateResponse
+	if err := c.getJSON(ctx, user, path, &resp); err != nil {
+		return 0, err
+	}
+	if len(resp.ActivitiesHeart) == 0 {
+		return 0, nil
+	}
+	return resp.ActivitiesHeart[0].Value.RestingHeartRate, nil
+}
+
+type sleepResponse struct {
+	Summary struct {
+		TotalMinutesAsleep int `json:"totalMinutesAsleep"`
+	} `json:"summary"`
+}
+
+// FetchSleepMinutes returns total minutes asleep reported for date.
+func (c *Client) FetchSleepMinutes(ctx context.Context, user string, date time.Time) (int, error) {
+	path := fmt.Sprintf("/sleep/date/%s.json", date.Format("2006-01-02"))
+	var resp sleepResponse
+	if err := c.getJSON(ctx, user, path, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Summary.TotalMinutesAsleep, nil
+}
+
+type profileResponse struct {
+	User struct {
+		EncodedID string `json:"encodedId"`
+	} `json:"user"`
+}
+
+// FetchProfileID returns the Fitbit encoded user ID, useful for confirming
+// which account a stored token belongs to.
+func (c *Client) FetchProfileID(ctx context.Context, user string) (string, error) {
+	var resp profileResponse
+	if err := c.getJSON(ctx, user, "/profile.json", &resp); err != nil {
+		return "", err
+	}
+	return resp.User.EncodedID, nil
+}
+
+type dayExpenditure struct {
+	date        string
+	caloriesOut int
+}
+
+// ExpenditureRange fetches calories-out for every day in [from, until] and
+// returns it keyed by date, suitable for merging into WeightInsiderData.
+// Days are fetched concurrently through c.pool, which bounds how many
+// requests are in flight and retries 429/5xx responses, so a rate limit on
+// one day doesn't abort the whole range and leave it with zero data.
+func (c *Client) ExpenditureRange(ctx context.Context, user string, from, until time.Time) (map[string]int, error) {
+	var days []time.Time
+	for d := from; !d.After(until); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(days))
+	results := make(chan dayExpenditure, len(days))
+
+	for _, d := range days {
+		go func(d time.Time) {
+			defer wg.Done()
+			caloriesOut, _, err := c.FetchActivitySummary(ctx, user, d)
+			if err != nil {
+				log.Printf("fitbit: skipping %s: %v", d.Format("2006-01-02"), err)
+				return
+			}
+			results <- dayExpenditure{date: d.Format("2006-01-02"), caloriesOut: caloriesOut}
+		}(d)
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	data := make(map[string]int)
+	for r := range results {
+		if r.caloriesOut > 0 {
+			data[r.date] = r.caloriesOut
+		}
+	}
+	return data, nil
+}
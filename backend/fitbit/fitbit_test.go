@@ -0,0 +1,56 @@
+package fitbit
+
+import (
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func newTestClient(clientSecret string) *Client {
+	return &Client{config: &oauth2.Config{ClientSecret: clientSecret}}
+}
+
+func TestVerifyState(t *testing.T) {
+	c := newTestClient("test-client-secret")
+
+	tests := []struct {
+		name string
+		user string
+	}{
+		{name: "simple user", user: "alice"},
+		{name: "user with dot (email)", user: "tomasz.kaliciak@example.com"},
+		{name: "user with multiple dots", user: "a.b.c@example.com"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			state := c.csrfState(tc.user)
+			user, ok := c.verifyState(state)
+			if !ok {
+				t.Fatalf("verifyState(%q) = false, want true", state)
+			}
+			if user != tc.user {
+				t.Errorf("verifyState(%q) user = %q, want %q", state, user, tc.user)
+			}
+		})
+	}
+}
+
+func TestVerifyStateRejectsTampering(t *testing.T) {
+	c := newTestClient("test-client-secret")
+
+	state := c.csrfState("alice")
+	tampered := state[:len(state)-1] + "0"
+
+	if _, ok := c.verifyState(tampered); ok {
+		t.Fatalf("verifyState accepted a tampered state")
+	}
+}
+
+func TestVerifyStateRejectsMissingDelimiter(t *testing.T) {
+	c := newTestClient("test-client-secret")
+
+	if _, ok := c.verifyState("no-dot-here"); ok {
+		t.Fatalf("verifyState accepted a state with no delimiter")
+	}
+}
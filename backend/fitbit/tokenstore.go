@@ -0,0 +1,57 @@
+package fitbit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// tokenStore persists one OAuth2 token per user in a JSON file next to
+// credentials.json, mirroring how the Fitatu client keeps its own
+// credentials on disk.
+type tokenStore struct {
+	mu     sync.Mutex
+	path   string
+	tokens map[string]*oauth2.Token
+}
+
+func loadTokenStore(path string) (*tokenStore, error) {
+	store := &tokenStore{path: path, tokens: make(map[string]*oauth2.Token)}
+
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading token store %s: %w", path, err)
+	}
+
+	if len(raw) == 0 {
+		return store, nil
+	}
+	if err := json.Unmarshal(raw, &store.tokens); err != nil {
+		return nil, fmt.Errorf("error unmarshalling token store %s: %w", path, err)
+	}
+	return store, nil
+}
+
+func (s *tokenStore) get(user string) (*oauth2.Token, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[user]
+	return token, ok
+}
+
+func (s *tokenStore) set(user string, token *oauth2.Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[user] = token
+
+	raw, err := json.MarshalIndent(s.tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling token store: %w", err)
+	}
+	return os.WriteFile(s.path, raw, 0600)
+}
@@ -0,0 +1,285 @@
+// Package gfitwriter writes the daily calorie balance derived from
+// WeightInsiderData (CalorieIntake minus expenditure) back into the user's
+// Google Fit account as a custom derived DataSource, so it shows up in the
+// Fit app and any integration reading from it.
+package gfitwriter
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/fitness/v1"
+	"google.golang.org/api/option"
+)
+
+const (
+	TokensFile      = "gfit_tokens.json"
+	CredentialsFile = "gfit_credentials.json"
+
+	dataTypeName   = "com.google.calories.expended"
+	dataStreamName = "weight_insider_calorie_balance"
+	packageName    = "com.tomaszkaliciak.weightinsider"
+)
+
+var Endpoint = oauth2.Endpoint{
+	AuthURL:  "https://accounts.google.com/o/oauth2/auth",
+	TokenURL: "https://oauth2.googleapis.com/token",
+}
+
+// Credentials holds the Google Fit OAuth client registration, loaded from
+// CredentialsFile next to the Fitatu credentials.json.
+type Credentials struct {
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	RedirectURL  string `json:"redirectUrl"`
+}
+
+func loadCredentials(filename string) (*Credentials, error) {
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", filename, err)
+	}
+	var creds Credentials
+	if err := json.Unmarshal(raw, &creds); err != nil {
+		return nil, fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	return &creds, nil
+}
+
+// Writer drives the OAuth dance and writes daily calorie-balance points to
+// Google Fit for a single user. With dryRun set, WriteDailyBalance only
+// logs the writes it would have made.
+type Writer struct {
+	config *oauth2.Config
+	tokens *tokenStore
+	dryRun bool
+}
+
+// NewWriter loads credentials from credentialsFile and the token store next
+// to it.
+func NewWriter(credentialsFile string, dryRun bool) (*Writer, error) {
+	creds, err := loadCredentials(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &oauth2.Config{
+		ClientID:     creds.ClientID,
+		ClientSecret: creds.ClientSecret,
+		RedirectURL:  creds.RedirectURL,
+		Endpoint:     Endpoint,
+		Scopes:       []string{fitness.FitnessBodyWriteScope, fitness.FitnessActivityWriteScope},
+	}
+
+	tokens, err := loadTokenStore(TokensFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{config: config, tokens: tokens, dryRun: dryRun}, nil
+}
+
+// csrfState returns a per-user HMAC-signed state parameter so the callback
+// handler can verify ParseToken requests weren't forged.
+func (w *Writer) csrfState(user string) string {
+	mac := hmac.New(sha256.New, []byte(w.config.ClientSecret))
+	mac.Write([]byte(user))
+	return user + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *Writer) verifyState(state string) (user string, ok bool) {
+	idx := strings.LastIndex(state, ".")
+	if idx < 0 {
+		return "", false
+	}
+	user = state[:idx]
+	if !hmac.Equal([]byte(w.csrfState(user)), []byte(state)) {
+		return "", false
+	}
+	return user, true
+}
+
+// AuthURL returns the URL the user should be redirected to in order to
+// grant Google Fit write access.
+func (w *Writer) AuthURL(user string) string {
+	return w.config.AuthCodeURL(w.csrfState(user), oauth2.AccessTypeOffline)
+}
+
+// ParseToken handles the OAuth2 redirect callback, exchanging the
+// authorization code for a token and persisting it for the signed-in user.
+func (w *Writer) ParseToken(ctx context.Context, r *http.Request) (*oauth2.Token, error) {
+	query := r.URL.Query()
+	state := query.Get("state")
+	code := query.Get("code")
+
+	user, ok := w.verifyState(state)
+	if !ok {
+		return nil, fmt.Errorf("invalid or tampered CSRF state parameter")
+	}
+
+	token, err := w.config.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("error exchanging authorization code: %w", err)
+	}
+
+	if err := w.tokens.set(user, token); err != nil {
+		return nil, fmt.Errorf("error persisting token for %s: %w", user, err)
+	}
+
+	return token, nil
+}
+
+// httpClient returns an http.Client that transparently refreshes the stored
+// token for user when it expires.
+func (w *Writer) httpClient(ctx context.Context, user string) (*http.Client, error) {
+	token, ok := w.tokens.get(user)
+	if !ok {
+		return nil, fmt.Errorf("no stored Google Fit token for user %s; visit AuthURL first", user)
+	}
+
+	src := w.config.TokenSource(ctx, token)
+	refreshed, err := src.Token()
+	if err != nil {
+		return nil, fmt.Errorf("error refreshing Google Fit token: %w", err)
+	}
+	if refreshed.AccessToken != token.AccessToken {
+		if err := w.tokens.set(user, refreshed); err != nil {
+			return nil, fmt.Errorf("error persisting refreshed token: %w", err)
+		}
+	}
+
+	return oauth2.NewClient(ctx, oauth2.StaticTokenSource(refreshed)), nil
+}
+
+// newDataSource builds the derived DataSource weight-insider publishes
+// calorie balance under. Google Fit computes the DataStreamId from its
+// type, dataStreamName, application.packageName, and device fields, so
+// creating the same struct twice always resolves to the same stream.
+func newDataSource() *fitness.DataSource {
+	return &fitness.DataSource{
+		Type:           "derived",
+		DataStreamName: dataStreamName,
+		Application: &fitness.Application{
+			PackageName: packageName,
+		},
+		Device: &fitness.Device{
+			Manufacturer: "weight-insider",
+			Model:        "calorie-balance",
+			Type:         "unknown",
+			Uid:          "weight-insider-1",
+		},
+		DataType: &fitness.DataType{
+			Name: dataTypeName,
+			Field: []*fitness.DataTypeField{
+				{Name: "calories", Format: "floatPoint"},
+			},
+		},
+	}
+}
+
+// dataStreamID predicts the DataStreamId Google Fit assigns newDataSource,
+// following the documented {type}:{dataType}:{packageName}:{device
+// fields}:{dataStreamName} scheme, so callers can look up the stream before
+// deciding whether to create it.
+func dataStreamID(ds *fitness.DataSource) string {
+	return fmt.Sprintf("%s:%s:%s:%s:%s:%s:%s",
+		ds.Type, dataTypeName, packageName,
+		ds.Device.Manufacturer, ds.Device.Model, ds.Device.Uid,
+		dataStreamName)
+}
+
+// ensureDataSource returns the existing weight-insider DataSource for user,
+// creating it first if this is the first write.
+func ensureDataSource(ctx context.Context, svc *fitness.Service) (*fitness.DataSource, error) {
+	ds := newDataSource()
+	streamID := dataStreamID(ds)
+
+	if existing, err := svc.Users.DataSources.Get("me", streamID).Context(ctx).Do(); err == nil {
+		return existing, nil
+	}
+
+	created, err := svc.Users.DataSources.Create("me", ds).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error creating data source: %w", err)
+	}
+	return created, nil
+}
+
+// dayBoundsNanos returns the nanosecond epoch range covering all of date
+// (format "2006-01-02") in UTC.
+func dayBoundsNanos(date string) (start, end int64, err error) {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error parsing date %s: %w", date, err)
+	}
+	start = day.UnixNano()
+	end = day.AddDate(0, 0, 1).UnixNano() - 1
+	return start, end, nil
+}
+
+// WriteDailyBalance patches one Dataset per day in balance (date ->
+// CalorieIntake - expenditure, in kcal) into the user's Google Fit account.
+// With dryRun set on the Writer, it only logs what it would have written.
+func (w *Writer) WriteDailyBalance(ctx context.Context, user string, balance map[string]int) error {
+	if w.dryRun {
+		for date, kcal := range balance {
+			log.Printf("[dry-run] would write calorie balance %d kcal for %s", kcal, date)
+		}
+		return nil
+	}
+
+	client, err := w.httpClient(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	svc, err := fitness.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return fmt.Errorf("error creating fitness service: %w", err)
+	}
+
+	dataSource, err := ensureDataSource(ctx, svc)
+	if err != nil {
+		return err
+	}
+
+	for date, kcal := range balance {
+		startNanos, endNanos, err := dayBoundsNanos(date)
+		if err != nil {
+			return err
+		}
+
+		dataset := &fitness.Dataset{
+			DataSourceId:   dataSource.DataStreamId,
+			MinStartTimeNs: startNanos,
+			MaxEndTimeNs:   endNanos,
+			Point: []*fitness.DataPoint{
+				{
+					DataTypeName:   dataTypeName,
+					StartTimeNanos: startNanos,
+					EndTimeNanos:   endNanos,
+					Value: []*fitness.Value{
+						{FpVal: float64(kcal)},
+					},
+				},
+			},
+		}
+
+		datasetID := fmt.Sprintf("%d-%d", startNanos, endNanos)
+		if _, err := svc.Users.DataSources.Datasets.Patch("me", dataSource.DataStreamId, datasetID, dataset).Context(ctx).Do(); err != nil {
+			return fmt.Errorf("error patching dataset for %s: %w", date, err)
+		}
+	}
+
+	return nil
+}
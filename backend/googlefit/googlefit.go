@@ -0,0 +1,218 @@
+// Package googlefit implements a minimal OAuth2 client for the Google Fit
+// REST API, used to pull weight and expenditure directly from a user's
+// Google account instead of requiring a Health Connect SQLite export.
+package googlefit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/fitness/v1"
+	"google.golang.org/api/option"
+)
+
+const (
+	TokensFile      = "googlefit_tokens.json"
+	CredentialsFile = "googlefit_credentials.json"
+
+	weightDataTypeName      = "com.google.weight"
+	expenditureDataTypeName = "com.google.calories.expended"
+	oneDayMillis            = 24 * 60 * 60 * 1000
+)
+
+var Endpoint = oauth2.Endpoint{
+	AuthURL:  "https://accounts.google.com/o/oauth2/auth",
+	TokenURL: "https://oauth2.googleapis.com/token",
+}
+
+// Credentials holds the Google Fit OAuth client registration, loaded from
+// CredentialsFile next to the Fitatu credentials.json.
+type Credentials struct {
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	RedirectURL  string `json:"redirectUrl"`
+}
+
+func loadCredentials(filename string) (*Credentials, error) {
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", filename, err)
+	}
+	var creds Credentials
+	if err := json.Unmarshal(raw, &creds); err != nil {
+		return nil, fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	return &creds, nil
+}
+
+// Client drives the 3-legged OAuth dance and reads daily weight and
+// expenditure aggregates for a single Google account.
+type Client struct {
+	config *oauth2.Config
+	tokens *tokenStore
+}
+
+func NewClient(credentialsFile string) (*Client, error) {
+	creds, err := loadCredentials(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &oauth2.Config{
+		ClientID:     creds.ClientID,
+		ClientSecret: creds.ClientSecret,
+		RedirectURL:  creds.RedirectURL,
+		Endpoint:     Endpoint,
+		Scopes:       []string{fitness.FitnessBodyReadScope, fitness.FitnessActivityReadScope},
+	}
+
+	tokens, err := loadTokenStore(TokensFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{config: config, tokens: tokens}, nil
+}
+
+// csrfState returns a per-user HMAC-signed state parameter so the callback
+// handler can verify ParseToken requests weren't forged.
+func (c *Client) csrfState(user string) string {
+	mac := hmac.New(sha256.New, []byte(c.config.ClientSecret))
+	mac.Write([]byte(user))
+	return user + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c *Client) verifyState(state string) (user string, ok bool) {
+	idx := strings.LastIndex(state, ".")
+	if idx < 0 {
+		return "", false
+	}
+	user = state[:idx]
+	if !hmac.Equal([]byte(c.csrfState(user)), []byte(state)) {
+		return "", false
+	}
+	return user, true
+}
+
+// AuthURL returns the URL the user should be redirected to in order to
+// grant access to their Google Fit data.
+func (c *Client) AuthURL(user string) string {
+	return c.config.AuthCodeURL(c.csrfState(user), oauth2.AccessTypeOffline)
+}
+
+// ParseToken handles the OAuth2 redirect callback, exchanging the
+// authorization code for a token and persisting it for the signed-in user.
+func (c *Client) ParseToken(ctx context.Context, r *http.Request) (*oauth2.Token, error) {
+	query := r.URL.Query()
+	state := query.Get("state")
+	code := query.Get("code")
+
+	user, ok := c.verifyState(state)
+	if !ok {
+		return nil, fmt.Errorf("invalid or tampered CSRF state parameter")
+	}
+
+	token, err := c.config.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("error exchanging authorization code: %w", err)
+	}
+
+	if err := c.tokens.set(user, token); err != nil {
+		return nil, fmt.Errorf("error persisting token for %s: %w", user, err)
+	}
+
+	return token, nil
+}
+
+// httpClient returns an http.Client that transparently refreshes the stored
+// token for user when it expires.
+func (c *Client) httpClient(ctx context.Context, user string) (*http.Client, error) {
+	token, ok := c.tokens.get(user)
+	if !ok {
+		return nil, fmt.Errorf("no stored Google Fit token for user %s; visit AuthURL first", user)
+	}
+
+	src := c.config.TokenSource(ctx, token)
+	refreshed, err := src.Token()
+	if err != nil {
+		return nil, fmt.Errorf("error refreshing Google Fit token: %w", err)
+	}
+	if refreshed.AccessToken != token.AccessToken {
+		if err := c.tokens.set(user, refreshed); err != nil {
+			return nil, fmt.Errorf("error persisting refreshed token: %w", err)
+		}
+	}
+
+	return oauth2.NewClient(ctx, oauth2.StaticTokenSource(refreshed)), nil
+}
+
+// aggregateDaily runs a Users.Dataset.Aggregate request bucketed by day for
+// dataTypeName over [from, until], keyed by "2006-01-02" date.
+func (c *Client) aggregateDaily(ctx context.Context, user, dataTypeName string, from, until time.Time) (map[string]float64, error) {
+	client, err := c.httpClient(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	svc, err := fitness.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("error creating fitness service: %w", err)
+	}
+
+	request := &fitness.AggregateRequest{
+		AggregateBy:     []*fitness.AggregateBy{{DataTypeName: dataTypeName}},
+		BucketByTime:    &fitness.BucketByTime{DurationMillis: oneDayMillis},
+		StartTimeMillis: from.UnixMilli(),
+		EndTimeMillis:   until.UnixMilli(),
+	}
+
+	response, err := svc.Users.Dataset.Aggregate("me", request).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error aggregating %s: %w", dataTypeName, err)
+	}
+
+	values := make(map[string]float64)
+	for _, bucket := range response.Bucket {
+		date := time.UnixMilli(bucket.StartTimeMillis).UTC().Format("2006-01-02")
+		for _, dataset := range bucket.Dataset {
+			for _, point := range dataset.Point {
+				for _, value := range point.Value {
+					if value.FpVal > 0 {
+						values[date] = value.FpVal
+					}
+				}
+			}
+		}
+	}
+	return values, nil
+}
+
+// FetchWeightRange returns weight in kilograms, keyed by "2006-01-02" date,
+// for every day Google Fit has a sample in [from, until].
+func (c *Client) FetchWeightRange(ctx context.Context, user string, from, until time.Time) (map[string]float64, error) {
+	return c.aggregateDaily(ctx, user, weightDataTypeName, from, until)
+}
+
+// FetchExpenditureRange returns calories burned, keyed by "2006-01-02" date,
+// for every day Google Fit has a total in [from, until].
+func (c *Client) FetchExpenditureRange(ctx context.Context, user string, from, until time.Time) (map[string]int, error) {
+	kcal, err := c.aggregateDaily(ctx, user, expenditureDataTypeName, from, until)
+	if err != nil {
+		return nil, err
+	}
+
+	expenditure := make(map[string]int, len(kcal))
+	for date, value := range kcal {
+		expenditure[date] = int(value)
+	}
+	return expenditure, nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultJWKSURL is where Fitatu publishes the RSA keys it signs tokens
+	// with; overridable per-install via Credentials.JWKSURL.
+	DefaultJWKSURL = "https://pl-pl.fitatu.com/.well-known/jwks.json"
+
+	FitatuJWTIssuer   = "pl-pl.fitatu.com"
+	FitatuJWTAudience = ApiKey
+
+	jwksCacheTTL = 1 * time.Hour
+)
+
+// Claims is the typed subset of a Fitatu JWT payload callers can rely on,
+// populated only once Verify has checked the signature.
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  string
+	ExpiresAt time.Time
+	NotBefore time.Time
+}
+
+// jwk is a single entry of a JWKS document (RFC 7517), restricted to the
+// RSA fields Fitatu's RS256 keys use.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwksDocument struct {
+	Keys []jwk `json:"keys"`
+}
+
+// JWKSKeySet resolves a JWT's "kid" to an RSA public key, fetching and
+// caching the JWKS document from url, and/or holds the shared secret HS256
+// tokens are signed with. Either may be unused depending on which alg
+// Fitatu issues.
+type JWKSKeySet struct {
+	url         string
+	hs256Secret []byte
+	client      *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+// NewJWKSKeySet returns a JWKSKeySet that fetches RSA keys from url (used
+// for RS256) and/or verifies against hs256Secret (used for HS256); either
+// may be left empty if that alg isn't expected.
+func NewJWKSKeySet(url string, hs256Secret []byte) *JWKSKeySet {
+	return &JWKSKeySet{url: url, hs256Secret: hs256Secret, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (k *JWKSKeySet) rsaKey(kid string) (*rsa.PublicKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if key, ok := k.keys[kid]; ok && time.Since(k.fetched) < jwksCacheTTL {
+		return key, nil
+	}
+	if err := k.refresh(); err != nil {
+		return nil, err
+	}
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("no JWKS key for kid %q", kid)
+	}
+	return key, nil
+}
+
+func (k *JWKSKeySet) refresh() error {
+	resp, err := k.client.Get(k.url)
+	if err != nil {
+		return fmt.Errorf("error fetching JWKS from %s: %w", k.url, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading JWKS response: %w", err)
+	}
+
+	var doc jwksDocument
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return fmt.Errorf("error unmarshalling JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(doc.Keys))
+	for _, key := range doc.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+		pub, err := rsaPublicKeyFromJWK(key)
+		if err != nil {
+			continue
+		}
+		keys[key.Kid] = pub
+	}
+
+	k.keys = keys
+	k.fetched = time.Now()
+	return nil
+}
+
+func rsaPublicKeyFromJWK(key jwk) (*rsa.PublicKey, error) {
+	nBytes, err := decodeBase64(key.N)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding modulus: %w", err)
+	}
+	eBytes, err := decodeBase64(key.E)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding exponent: %w", err)
+	}
+
+	e := 0
+	for _, b := range eBytes {
+		e = e<<8 | int(b)
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nBytes), E: e}, nil
+}
+
+// Verify checks token's signature (RS256 against keys' JWKS, or HS256
+// against keys' shared secret, whichever j's header names) and validates
+// exp, nbf, iss, and aud, returning the decoded claims on success.
+func (j *JWT) Verify(keys *JWKSKeySet, rawToken string) (*Claims, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid JWT format")
+	}
+	signingInput := parts[0] + "." + parts[1]
+	signature, err := decodeBase64(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("error decoding signature: %w", err)
+	}
+
+	alg, _ := j.Header["alg"].(string)
+	switch alg {
+	case "RS256":
+		kid, _ := j.Header["kid"].(string)
+		pub, err := keys.rsaKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		hashed := sha256.Sum256([]byte(signingInput))
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, hashed[:], signature); err != nil {
+			return nil, fmt.Errorf("invalid RS256 signature: %w", err)
+		}
+	case "HS256":
+		if len(keys.hs256Secret) == 0 {
+			return nil, fmt.Errorf("token is HS256 but no shared secret is configured")
+		}
+		mac := hmac.New(sha256.New, keys.hs256Secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return nil, fmt.Errorf("invalid HS256 signature")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported JWT alg %q", alg)
+	}
+
+	claims := j.claims()
+
+	now := time.Now()
+	if !claims.ExpiresAt.IsZero() && now.After(claims.ExpiresAt) {
+		return nil, fmt.Errorf("token expired at %s", claims.ExpiresAt)
+	}
+	if !claims.NotBefore.IsZero() && now.Before(claims.NotBefore) {
+		return nil, fmt.Errorf("token not valid until %s", claims.NotBefore)
+	}
+	if claims.Issuer != "" && claims.Issuer != FitatuJWTIssuer {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if claims.Audience != "" && claims.Audience != FitatuJWTAudience {
+		return nil, fmt.Errorf("unexpected audience %q", claims.Audience)
+	}
+
+	return claims, nil
+}
+
+func (j *JWT) claims() *Claims {
+	claims := &Claims{}
+	if sub, ok := j.Payload["id"].(string); ok {
+		claims.Subject = sub
+	}
+	if iss, ok := j.Payload["iss"].(string); ok {
+		claims.Issuer = iss
+	}
+	if aud, ok := j.Payload["aud"].(string); ok {
+		claims.Audience = aud
+	}
+	if exp, ok := j.Payload["exp"].(float64); ok {
+		claims.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+	if nbf, ok := j.Payload["nbf"].(float64); ok {
+		claims.NotBefore = time.Unix(int64(nbf), 0)
+	}
+	return claims
+}
+
+var (
+	fitatuJWKSOnce sync.Once
+	fitatuJWKS     *JWKSKeySet
+)
+
+// verifyFitatuToken decodes and verifies a freshly issued Fitatu token so
+// TokenManager never trusts an "id" claim out of a tampered or expired
+// token. The JWKS client is built once and reused across logins.
+func verifyFitatuToken(rawToken string, credentials *Credentials) (*Claims, error) {
+	jwtData, err := DecodeJWT(rawToken)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding JWT: %w", err)
+	}
+
+	fitatuJWKSOnce.Do(func() {
+		url := credentials.JWKSURL
+		if url == "" {
+			url = DefaultJWKSURL
+		}
+		fitatuJWKS = NewJWKSKeySet(url, []byte(credentials.JWTSecret))
+	})
+
+	return jwtData.Verify(fitatuJWKS, rawToken)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// signHS256Token builds a JWT string signed with secret, so tests can
+// exercise Verify without standing up a JWKS server.
+func signHS256Token(t *testing.T, secret []byte, header, payload map[string]any) string {
+	t.Helper()
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		t.Fatalf("marshal header: %v", err)
+	}
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("marshal payload: %v", err)
+	}
+
+	encHeader := base64.RawURLEncoding.EncodeToString(headerJSON)
+	encPayload := base64.RawURLEncoding.EncodeToString(payloadJSON)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encHeader + "." + encPayload))
+	encSignature := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	return encHeader + "." + encPayload + "." + encSignature
+}
+
+func TestJWTVerify(t *testing.T) {
+	secret := []byte("test-secret")
+	validPayload := map[string]any{
+		"id":  "user-1",
+		"iss": FitatuJWTIssuer,
+		"aud": FitatuJWTAudience,
+		"exp": float64(time.Now().Add(time.Hour).Unix()),
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		keySecret []byte
+		wantErr   bool
+	}{
+		{
+			name:      "valid signature",
+			token:     signHS256Token(t, secret, map[string]any{"alg": "HS256"}, validPayload),
+			keySecret: secret,
+		},
+		{
+			name:      "tampered signature",
+			token:     signHS256Token(t, []byte("wrong-secret"), map[string]any{"alg": "HS256"}, validPayload),
+			keySecret: secret,
+			wantErr:   true,
+		},
+		{
+			name: "expired token",
+			token: signHS256Token(t, secret, map[string]any{"alg": "HS256"}, map[string]any{
+				"id":  "user-1",
+				"iss": FitatuJWTIssuer,
+				"aud": FitatuJWTAudience,
+				"exp": float64(time.Now().Add(-time.Hour).Unix()),
+			}),
+			keySecret: secret,
+			wantErr:   true,
+		},
+		{
+			name:      "unsupported alg",
+			token:     signHS256Token(t, secret, map[string]any{"alg": "none"}, validPayload),
+			keySecret: secret,
+			wantErr:   true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			jwtData, err := DecodeJWT(tc.token)
+			if err != nil {
+				t.Fatalf("DecodeJWT: %v", err)
+			}
+
+			keys := NewJWKSKeySet("", tc.keySecret)
+			claims, err := jwtData.Verify(keys, tc.token)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Verify: expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: unexpected error: %v", err)
+			}
+			if claims.Subject != "user-1" {
+				t.Errorf("Subject = %q, want %q", claims.Subject, "user-1")
+			}
+		})
+	}
+}
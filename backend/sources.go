@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/tomaszkaliciak/weight-insider/backend/applehealth"
+	"github.com/tomaszkaliciak/weight-insider/backend/fetcher"
+	"github.com/tomaszkaliciak/weight-insider/backend/fitbit"
+	"github.com/tomaszkaliciak/weight-insider/backend/googlefit"
+	"github.com/tomaszkaliciak/weight-insider/backend/withings"
+)
+
+// SourceResult carries whichever WeightInsiderData fields a Source was able
+// to populate. Fields left nil are simply not merged.
+type SourceResult struct {
+	Weights              map[string]float64
+	BodyFat              map[string]float64
+	CalorieIntake        map[string]int
+	GoogleFitExpenditure map[string]int
+	FitbitExpenditure    map[string]int
+	Steps                map[string]int
+	DistanceMeters       map[string]float64
+	RestingHR            map[string]int
+	SleepMinutes         map[string]int
+}
+
+// Source is a pluggable provider of daily data, keyed by "2006-01-02" date
+// strings, that main merges into WeightInsiderData.
+type Source interface {
+	Name() string
+	Fetch(ctx context.Context) (SourceResult, error)
+}
+
+// fitatuSource logs into Fitatu and pulls weights and the last 90 days of
+// calorie intake. tokens keeps the bearer it authenticates with fresh
+// across calls and across runs; syncStatePath lets weight fetches backfill
+// history once and only pull the delta on every run after that.
+type fitatuSource struct {
+	pool          *fetcher.Pool
+	tokens        *TokenManager
+	syncStatePath string
+}
+
+func (s *fitatuSource) Name() string { return "fitatu" }
+
+func (s *fitatuSource) Fetch(ctx context.Context) (SourceResult, error) {
+	userID, err := s.tokens.UserID()
+	if err != nil {
+		return SourceResult{}, fmt.Errorf("fitatu login failed: %w", err)
+	}
+
+	result := SourceResult{Weights: make(map[string]float64), CalorieIntake: make(map[string]int)}
+
+	state, err := loadSyncState(s.syncStatePath)
+	if err != nil {
+		return SourceResult{}, fmt.Errorf("failed to load weight sync state: %w", err)
+	}
+
+	weights, summary, err := syncWeights(s.pool, userID, s.tokens, state)
+	if err != nil {
+		return SourceResult{}, fmt.Errorf("failed to fetch weight data: %w", err)
+	}
+	result.Weights = weights
+
+	if err := state.save(s.syncStatePath); err != nil {
+		return SourceResult{}, fmt.Errorf("failed to persist weight sync state: %w", err)
+	}
+	log.Printf("fitatu: weight sync %s", summary)
+
+	now := time.Now().UTC()
+	numRequests := 90
+
+	var wg sync.WaitGroup
+	wg.Add(numRequests)
+	results := make(chan PlanDataDay, numRequests)
+
+	for i := 0; i < numRequests; i++ {
+		go func(dayOffset int) {
+			defer wg.Done()
+			dateToCheck := now.AddDate(0, 0, -dayOffset)
+			intakeData, err := fetchintakeData(s.pool, userID, s.tokens, dateToCheck)
+			if err == nil {
+				results <- PlanDataDay{planData: *intakeData, calendarDay: dateToCheck}
+			}
+		}(i)
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for result_ := range results {
+		sum := 0.0
+		for _, value := range result_.planData.DietPlan {
+			for _, element := range value.Items {
+				sum += element.Energy
+			}
+		}
+		if sum > 0 {
+			result.CalorieIntake[result_.calendarDay.Format("2006-01-02")] = int(sum)
+		}
+	}
+
+	return result, nil
+}
+
+// healthConnectSource reads calorie expenditure, steps, distance, resting
+// heart rate, and sleep out of a Health Connect SQLite export. When more
+// than one app reports the same day, trustedAppInfoIDs (in priority order)
+// decides which app's numbers win instead of summing across apps.
+type healthConnectSource struct {
+	dbPath            string
+	trustedAppInfoIDs []int64
+}
+
+func (s *healthConnectSource) Name() string { return "health_connect" }
+
+func (s *healthConnectSource) Fetch(ctx context.Context) (SourceResult, error) {
+	db, err := sql.Open("sqlite3", s.dbPath)
+	if err != nil {
+		return SourceResult{}, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	calorieRecords, err := fetchTotalCaloriesBurnedRecords(db)
+	if err != nil {
+		return SourceResult{}, fmt.Errorf("could not fetch total calories burned records: %w", err)
+	}
+	expenditure := make(map[string]int)
+	for date, kcal := range getCaloriesBurnedRecords(calorieRecords, s.trustedAppInfoIDs) {
+		expenditure[date] = int(kcal)
+	}
+
+	stepsRecords, err := fetchStepsRecords(db)
+	if err != nil {
+		return SourceResult{}, fmt.Errorf("could not fetch steps records: %w", err)
+	}
+
+	distanceRecords, err := fetchDistanceRecords(db)
+	if err != nil {
+		return SourceResult{}, fmt.Errorf("could not fetch distance records: %w", err)
+	}
+
+	heartRateRecords, err := fetchHeartRateRecords(db)
+	if err != nil {
+		return SourceResult{}, fmt.Errorf("could not fetch heart rate records: %w", err)
+	}
+
+	sleepRecords, err := fetchSleepSessionRecords(db)
+	if err != nil {
+		return SourceResult{}, fmt.Errorf("could not fetch sleep session records: %w", err)
+	}
+
+	return SourceResult{
+		GoogleFitExpenditure: expenditure,
+		Steps:                getStepsRecords(stepsRecords, s.trustedAppInfoIDs),
+		DistanceMeters:       getDistanceRecords(distanceRecords, s.trustedAppInfoIDs),
+		RestingHR:            getRestingHRRecords(heartRateRecords, s.trustedAppInfoIDs),
+		SleepMinutes:         getSleepMinutesRecords(sleepRecords, s.trustedAppInfoIDs),
+	}, nil
+}
+
+// fitbitSource pulls calories-out from the Fitbit API for a single
+// authorized user, for use in place of (or alongside) the Health Connect
+// export.
+type fitbitSource struct {
+	client *fitbit.Client
+	user   string
+	days   int
+}
+
+func (s *fitbitSource) Name() string { return "fitbit" }
+
+func (s *fitbitSource) Fetch(ctx context.Context) (SourceResult, error) {
+	until := time.Now().UTC()
+	from := until.AddDate(0, 0, -s.days)
+
+	expenditure, err := s.client.ExpenditureRange(ctx, s.user, from, until)
+	if err != nil {
+		return SourceResult{}, fmt.Errorf("failed to fetch fitbit expenditure: %w", err)
+	}
+
+	return SourceResult{FitbitExpenditure: expenditure}, nil
+}
+
+// googleFitSource pulls weight and expenditure straight from the Google
+// Fit API for a single authorized account, for use in place of (or
+// alongside) a Health Connect export.
+type googleFitSource struct {
+	client *googlefit.Client
+	user   string
+	days   int
+}
+
+func (s *googleFitSource) Name() string { return "google_fit" }
+
+func (s *googleFitSource) Fetch(ctx context.Context) (SourceResult, error) {
+	until := time.Now().UTC()
+	from := until.AddDate(0, 0, -s.days)
+
+	weights, err := s.client.FetchWeightRange(ctx, s.user, from, until)
+	if err != nil {
+		return SourceResult{}, fmt.Errorf("failed to fetch google fit weight: %w", err)
+	}
+
+	expenditure, err := s.client.FetchExpenditureRange(ctx, s.user, from, until)
+	if err != nil {
+		return SourceResult{}, fmt.Errorf("failed to fetch google fit expenditure: %w", err)
+	}
+
+	return SourceResult{Weights: weights, GoogleFitExpenditure: expenditure}, nil
+}
+
+// withingsSource pulls weight and body fat from a user's Withings smart
+// scale, for use in place of (or alongside) Fitatu.
+type withingsSource struct {
+	client *withings.Client
+	user   string
+	days   int
+}
+
+func (s *withingsSource) Name() string { return "withings" }
+
+func (s *withingsSource) Fetch(ctx context.Context) (SourceResult, error) {
+	until := time.Now().UTC()
+	from := until.AddDate(0, 0, -s.days)
+
+	weights, err := s.client.FetchWeightRange(ctx, s.user, from, until)
+	if err != nil {
+		return SourceResult{}, fmt.Errorf("failed to fetch withings weight: %w", err)
+	}
+
+	bodyFat, err := s.client.FetchBodyFatRange(ctx, s.user, from, until)
+	if err != nil {
+		return SourceResult{}, fmt.Errorf("failed to fetch withings body fat: %w", err)
+	}
+
+	return SourceResult{Weights: weights, BodyFat: bodyFat}, nil
+}
+
+// appleHealthSource reads weight and body fat out of a local iOS Health
+// app export.xml. It needs no credentials, just a file path.
+type appleHealthSource struct {
+	exportPath string
+}
+
+func (s *appleHealthSource) Name() string { return "apple_health" }
+
+func (s *appleHealthSource) Fetch(ctx context.Context) (SourceResult, error) {
+	export, err := applehealth.Parse(s.exportPath)
+	if err != nil {
+		return SourceResult{}, fmt.Errorf("failed to parse apple health export: %w", err)
+	}
+	return SourceResult{Weights: export.Weights, BodyFat: export.BodyFat}, nil
+}
+
+// mergeSourceResult copies every populated field of r into data. A weight
+// that disagrees with what's already on record for that date is a
+// correction, not an overwrite: the old value is kept under data.History
+// instead of being discarded.
+func mergeSourceResult(data *WeightInsiderData, r SourceResult) {
+	for date, weight := range r.Weights {
+		if existing, ok := data.Weights[date]; ok && existing != weight {
+			data.History[date] = append(data.History[date], WeightHistoryEntry{Value: existing, RecordedAt: time.Now().UTC()})
+		}
+		data.Weights[date] = weight
+	}
+	for date, fat := range r.BodyFat {
+		data.BodyFat[date] = fat
+	}
+	for date, kcal := range r.CalorieIntake {
+		data.CalorieIntake[date] = kcal
+	}
+	for date, kcal := range r.GoogleFitExpenditure {
+		data.GoogleFitExpenditure[date] = kcal
+	}
+	for date, kcal := range r.FitbitExpenditure {
+		data.FitbitExpenditure[date] = kcal
+	}
+	for date, steps := range r.Steps {
+		data.Steps[date] = steps
+	}
+	for date, meters := range r.DistanceMeters {
+		data.DistanceMeters[date] = meters
+	}
+	for date, bpm := range r.RestingHR {
+		data.RestingHR[date] = bpm
+	}
+	for date, minutes := range r.SleepMinutes {
+		data.SleepMinutes[date] = minutes
+	}
+}
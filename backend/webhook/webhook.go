@@ -0,0 +1,225 @@
+// Package webhook receives subscription push notifications (Fitbit-style)
+// and triggers a targeted re-fetch for only the affected user/date, instead
+// of the exporter's "always pull the last 90 days" loop.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Notification mirrors a single entry of the JSON array Fitbit (and
+// Fitatu, where it has an equivalent) POSTs to the subscriber endpoint.
+type Notification struct {
+	OwnerID        string `json:"ownerId"`
+	CollectionType string `json:"collectionType"`
+	Date           string `json:"date"`
+	SubscriptionID string `json:"subscriptionId"`
+}
+
+func (n Notification) key() string {
+	return n.OwnerID + "|" + n.CollectionType + "|" + n.Date
+}
+
+// SyncFunc re-fetches data for a single user/date, scoped to collectionType.
+// It's injected by the caller so this package stays independent of
+// WeightInsiderData's schema.
+type SyncFunc func(ctx *SyncContext) error
+
+// SyncContext carries the parameters of a single delayed sync job.
+type SyncContext struct {
+	OwnerID        string
+	CollectionType string
+	Date           string
+}
+
+// Server receives webhook POSTs, verifies their authenticity, and enqueues
+// delayed sync jobs onto a bounded worker pool so bursts of notifications
+// don't spawn a goroutine per notification.
+type Server struct {
+	Verify      func(body []byte, r *http.Request) bool
+	Sync        SyncFunc
+	SyncDelay   time.Duration
+	StateFile   string
+	WorkerCount int
+
+	queue   chan Notification
+	seen    map[string]time.Time
+	seenMu  sync.Mutex
+	lastRun time.Time
+	once    sync.Once
+}
+
+const defaultWorkerCount = 4
+
+// NewServer constructs a Server ready to Start. stateFile records when the
+// last notification was synced successfully; it is written after each sync
+// and read back on Start, but it is not consulted when deciding whether to
+// enqueue a delivery. Repeated deliveries are dropped only by markSeen,
+// within a one-minute window of the same process.
+func NewServer(verify func(body []byte, r *http.Request) bool, sync SyncFunc, stateFile string) *Server {
+	return &Server{
+		Verify:      verify,
+		Sync:        sync,
+		SyncDelay:   5 * time.Second,
+		StateFile:   stateFile,
+		WorkerCount: defaultWorkerCount,
+		seen:        make(map[string]time.Time),
+	}
+}
+
+// Start launches the worker pool. Call once before serving requests.
+func (s *Server) Start() {
+	s.once.Do(func() {
+		if s.WorkerCount <= 0 {
+			s.WorkerCount = defaultWorkerCount
+		}
+		s.queue = make(chan Notification, 256)
+		s.lastRun = s.loadState()
+
+		for i := 0; i < s.WorkerCount; i++ {
+			go s.worker()
+		}
+	})
+}
+
+func (s *Server) worker() {
+	for n := range s.queue {
+		time.Sleep(s.SyncDelay)
+		err := s.Sync(&SyncContext{OwnerID: n.OwnerID, CollectionType: n.CollectionType, Date: n.Date})
+		if err != nil {
+			log.Printf("webhook: sync failed for %s: %v", n.key(), err)
+			continue
+		}
+		s.saveState(time.Now())
+	}
+}
+
+// ServeHTTP implements http.Handler, accepting the notification POST.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "error reading body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if s.Verify != nil && !s.Verify(body, r) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var notifications []Notification
+	if err := json.Unmarshal(body, &notifications); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	for _, n := range notifications {
+		if s.markSeen(n) {
+			continue
+		}
+		s.queue <- n
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// markSeen dedupes notifications that arrive more than once within a short
+// window (Fitbit retries deliveries it doesn't get a 2xx for).
+func (s *Server) markSeen(n Notification) (duplicate bool) {
+	s.seenMu.Lock()
+	defer s.seenMu.Unlock()
+
+	key := n.key()
+	if last, ok := s.seen[key]; ok && time.Since(last) < time.Minute {
+		return true
+	}
+	s.seen[key] = time.Now()
+	return false
+}
+
+type stateFile struct {
+	LastProcessed time.Time `json:"lastProcessed"`
+}
+
+func (s *Server) loadState() time.Time {
+	if s.StateFile == "" {
+		return time.Time{}
+	}
+	raw, err := os.ReadFile(s.StateFile)
+	if err != nil {
+		return time.Time{}
+	}
+	var state stateFile
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return time.Time{}
+	}
+	return state.LastProcessed
+}
+
+func (s *Server) saveState(t time.Time) {
+	if s.StateFile == "" {
+		return
+	}
+	s.lastRun = t
+	raw, err := json.MarshalIndent(stateFile{LastProcessed: t}, "", "  ")
+	if err != nil {
+		log.Printf("webhook: error marshalling state: %v", err)
+		return
+	}
+	if err := os.WriteFile(s.StateFile, raw, 0644); err != nil {
+		log.Printf("webhook: error writing state file %s: %v", s.StateFile, err)
+	}
+}
+
+// VerifyFitbitSignature checks the X-Fitbit-Signature header, which is
+// base64(HMAC-SHA1(clientSecret + "&", rawBody)).
+func VerifyFitbitSignature(clientSecret string) func(body []byte, r *http.Request) bool {
+	return func(body []byte, r *http.Request) bool {
+		signature := r.Header.Get("X-Fitbit-Signature")
+		if signature == "" {
+			return false
+		}
+
+		mac := hmac.New(sha1.New, []byte(clientSecret+"&"))
+		mac.Write(body)
+		expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+		return hmac.Equal([]byte(expected), []byte(signature))
+	}
+}
+
+// VerifyHMACSHA256 is a generic verifier for sources (like Fitatu, if it
+// ever adds webhooks) that sign with a shared secret rather than Fitbit's
+// "clientSecret + &" convention. header names the request header carrying
+// the hex-encoded signature.
+func VerifyHMACSHA256(secret []byte, header string) func(body []byte, r *http.Request) bool {
+	return func(body []byte, r *http.Request) bool {
+		signature := r.Header.Get(header)
+		if signature == "" {
+			return false
+		}
+
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(body)
+		expected := hex.EncodeToString(mac.Sum(nil))
+
+		return hmac.Equal([]byte(expected), []byte(signature))
+	}
+}
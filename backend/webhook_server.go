@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/tomaszkaliciak/weight-insider/backend/fitbit"
+	"github.com/tomaszkaliciak/weight-insider/backend/webhook"
+)
+
+const WebhookStateFile = "webhook_state.json"
+
+type fitbitWebhookCredentials struct {
+	ClientSecret string `json:"clientSecret"`
+}
+
+func loadFitbitWebhookCredentials(filename string) (*fitbitWebhookCredentials, error) {
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", filename, err)
+	}
+	var creds fitbitWebhookCredentials
+	if err := json.Unmarshal(raw, &creds); err != nil {
+		return nil, fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	return &creds, nil
+}
+
+// runWebhookServer starts an HTTP server that listens for Fitbit
+// subscription notifications and re-fetches only the affected user/date,
+// instead of the 90-day pull main() otherwise performs on every run.
+func runWebhookServer(addr string) error {
+	fitbitCreds, err := loadFitbitWebhookCredentials(fitbit.CredentialsFile)
+	if err != nil {
+		return fmt.Errorf("failed to load fitbit credentials: %w", err)
+	}
+
+	fitbitClient, err := fitbit.NewClient(fitbit.CredentialsFile)
+	if err != nil {
+		return fmt.Errorf("failed to build fitbit client: %w", err)
+	}
+
+	sync := func(syncCtx *webhook.SyncContext) error {
+		date, err := time.Parse("2006-01-02", syncCtx.Date)
+		if err != nil {
+			return fmt.Errorf("invalid notification date %q: %w", syncCtx.Date, err)
+		}
+
+		caloriesOut, _, err := fitbitClient.FetchActivitySummary(context.Background(), syncCtx.OwnerID, date)
+		if err != nil {
+			return err
+		}
+
+		dataFileMu.Lock()
+		defer dataFileMu.Unlock()
+
+		insiderData, err := loadOrInitData(DataJSONPath)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", DataJSONPath, err)
+		}
+		insiderData.FitbitExpenditure[syncCtx.Date] = caloriesOut
+
+		return saveData(DataJSONPath, insiderData)
+	}
+
+	server := webhook.NewServer(webhook.VerifyFitbitSignature(fitbitCreds.ClientSecret), sync, WebhookStateFile)
+	server.Start()
+
+	log.Printf("webhook: listening on %s", addr)
+	return http.ListenAndServe(addr, server)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/tomaszkaliciak/weight-insider/backend/fetcher"
+)
+
+const (
+	SyncStateFile = "sync_state.json"
+
+	// backfillWindow is how far back a first run (no prior SyncState) pulls
+	// weight history; after that, only the delta since LastSyncedAt is
+	// fetched.
+	backfillWindow = 2 * 365 * 24 * time.Hour
+	// chunkWindow bounds how much history a single request asks for, so a
+	// multi-year backfill pages through the API instead of one huge call.
+	chunkWindow = 30 * 24 * time.Hour
+	// reconcileWindow is re-fetched on every run, even past LastSyncedAt, so
+	// a correction Fitatu accepts for an already-synced date (the user
+	// edits last week's entry) is still picked up instead of being
+	// permanently shadowed by the delta cutoff.
+	reconcileWindow = 14 * 24 * time.Hour
+)
+
+// SyncState tracks, per Fitatu weight sync, when the last successful run
+// finished and what value we last saw for each date, so a re-run only
+// fetches what's actually new instead of re-pulling all of history.
+type SyncState struct {
+	LastSyncedAt time.Time         `json:"lastSyncedAt"`
+	Checksums    map[string]string `json:"checksums"`
+}
+
+func loadSyncState(filename string) (*SyncState, error) {
+	state := &SyncState{Checksums: make(map[string]string)}
+
+	raw, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", filename, err)
+	}
+	if len(raw) == 0 {
+		return state, nil
+	}
+	if err := json.Unmarshal(raw, state); err != nil {
+		return nil, fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	if state.Checksums == nil {
+		state.Checksums = make(map[string]string)
+	}
+	return state, nil
+}
+
+func (s *SyncState) save(filename string) error {
+	raw, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling sync state: %w", err)
+	}
+	return os.WriteFile(filename, raw, 0644)
+}
+
+// checksumWeight is a cheap fingerprint of a weight value, just precise
+// enough to tell "unchanged since last sync" from "corrected".
+func checksumWeight(value float64) string {
+	sum := crc32.ChecksumIEEE([]byte(strconv.FormatFloat(value, 'f', -1, 64)))
+	return strconv.FormatUint(uint64(sum), 16)
+}
+
+// WeightSyncSummary counts how a sync run classified every date it saw
+// against state's checksums.
+type WeightSyncSummary struct {
+	Added     int
+	Updated   int
+	Unchanged int
+}
+
+func (s WeightSyncSummary) String() string {
+	return fmt.Sprintf("%d added, %d updated, %d unchanged", s.Added, s.Updated, s.Unchanged)
+}
+
+// syncWeights fetches every weight Fitatu has reported since state's last
+// successful sync. With no prior state it pages through backfillWindow of
+// history in chunkWindow chunks; otherwise it asks for the delta since
+// LastSyncedAt widened to cover reconcileWindow, so a correction to a date
+// already synced is still caught. state is updated in place with fresh
+// checksums and the new LastSyncedAt; callers are responsible for
+// persisting it.
+func syncWeights(pool *fetcher.Pool, userID string, tokens *TokenManager, state *SyncState) (map[string]float64, WeightSyncSummary, error) {
+	now := time.Now().UTC()
+	from := state.LastSyncedAt
+	if from.IsZero() {
+		from = now.Add(-backfillWindow)
+	} else if reconcileFrom := now.Add(-reconcileWindow); reconcileFrom.Before(from) {
+		from = reconcileFrom
+	}
+
+	weights := make(map[string]float64)
+	for chunkStart := from; chunkStart.Before(now); chunkStart = chunkStart.Add(chunkWindow) {
+		chunkEnd := chunkStart.Add(chunkWindow)
+		if chunkEnd.After(now) {
+			chunkEnd = now
+		}
+
+		weightData, err := fetchWeightDataRange(pool, userID, tokens, chunkStart, chunkEnd)
+		if err != nil {
+			return nil, WeightSyncSummary{}, fmt.Errorf("failed to fetch weights for %s..%s: %w", chunkStart.Format("2006-01-02"), chunkEnd.Format("2006-01-02"), err)
+		}
+		for date, value := range weightData.Weights {
+			weights[date] = value
+		}
+	}
+
+	var summary WeightSyncSummary
+	for date, value := range weights {
+		checksum := checksumWeight(value)
+		switch prev, known := state.Checksums[date]; {
+		case !known:
+			summary.Added++
+		case prev == checksum:
+			summary.Unchanged++
+		default:
+			summary.Updated++
+		}
+		state.Checksums[date] = checksum
+	}
+	state.LastSyncedAt = now
+
+	return weights, summary, nil
+}
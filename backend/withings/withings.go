@@ -0,0 +1,248 @@
+// Package withings implements a minimal OAuth2 client for the Withings
+// Measure API, used as an alternative to Fitatu for weight and body fat.
+package withings
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	APIBaseURL      = "https://wbsapi.withings.net"
+	TokensFile      = "withings_tokens.json"
+	CredentialsFile = "withings_credentials.json"
+
+	// Withings measurement types, per the Measure API docs.
+	measTypeWeight   = 1
+	measTypeFatRatio = 6
+)
+
+var Endpoint = oauth2.Endpoint{
+	AuthURL:  "https://account.withings.com/oauth2_user/authorize2",
+	TokenURL: APIBaseURL + "/v2/oauth2",
+}
+
+// Credentials holds the Withings app registration, loaded from
+// CredentialsFile next to the Fitatu credentials.json.
+type Credentials struct {
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	RedirectURL  string `json:"redirectUrl"`
+}
+
+func loadCredentials(filename string) (*Credentials, error) {
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", filename, err)
+	}
+	var creds Credentials
+	if err := json.Unmarshal(raw, &creds); err != nil {
+		return nil, fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	return &creds, nil
+}
+
+// Client drives the 3-legged OAuth dance and fetches weight/body-fat
+// measurements for a single Withings user.
+type Client struct {
+	config *oauth2.Config
+	tokens *tokenStore
+}
+
+func NewClient(credentialsFile string) (*Client, error) {
+	creds, err := loadCredentials(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &oauth2.Config{
+		ClientID:     creds.ClientID,
+		ClientSecret: creds.ClientSecret,
+		RedirectURL:  creds.RedirectURL,
+		Endpoint:     Endpoint,
+		Scopes:       []string{"user.metrics"},
+	}
+
+	tokens, err := loadTokenStore(TokensFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{config: config, tokens: tokens}, nil
+}
+
+// csrfState returns a per-user HMAC-signed state parameter so the callback
+// handler can verify ParseToken requests weren't forged.
+func (c *Client) csrfState(user string) string {
+	mac := hmac.New(sha256.New, []byte(c.config.ClientSecret))
+	mac.Write([]byte(user))
+	return user + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c *Client) verifyState(state string) (user string, ok bool) {
+	idx := strings.LastIndex(state, ".")
+	if idx < 0 {
+		return "", false
+	}
+	user = state[:idx]
+	if !hmac.Equal([]byte(c.csrfState(user)), []byte(state)) {
+		return "", false
+	}
+	return user, true
+}
+
+// AuthURL returns the URL the user should be redirected to in order to grant
+// access to their Withings data.
+func (c *Client) AuthURL(user string) string {
+	return c.config.AuthCodeURL(c.csrfState(user))
+}
+
+// ParseToken handles the OAuth2 redirect callback, exchanging the
+// authorization code for a token and persisting it for the signed-in user.
+func (c *Client) ParseToken(ctx context.Context, r *http.Request) (*oauth2.Token, error) {
+	query := r.URL.Query()
+	state := query.Get("state")
+	code := query.Get("code")
+
+	user, ok := c.verifyState(state)
+	if !ok {
+		return nil, fmt.Errorf("invalid or tampered CSRF state parameter")
+	}
+
+	token, err := c.config.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("error exchanging authorization code: %w", err)
+	}
+
+	if err := c.tokens.set(user, token); err != nil {
+		return nil, fmt.Errorf("error persisting token for %s: %w", user, err)
+	}
+
+	return token, nil
+}
+
+// httpClient returns an http.Client that transparently refreshes the stored
+// token for user when it expires.
+func (c *Client) httpClient(ctx context.Context, user string) (*http.Client, error) {
+	token, ok := c.tokens.get(user)
+	if !ok {
+		return nil, fmt.Errorf("no stored Withings token for user %s; visit AuthURL first", user)
+	}
+
+	src := c.config.TokenSource(ctx, token)
+	refreshed, err := src.Token()
+	if err != nil {
+		return nil, fmt.Errorf("error refreshing Withings token: %w", err)
+	}
+	if refreshed.AccessToken != token.AccessToken {
+		if err := c.tokens.set(user, refreshed); err != nil {
+			return nil, fmt.Errorf("error persisting refreshed token: %w", err)
+		}
+	}
+
+	return oauth2.NewClient(ctx, oauth2.StaticTokenSource(refreshed)), nil
+}
+
+type measureResponse struct {
+	Status int `json:"status"`
+	Body   struct {
+		MeasureGroups []struct {
+			Date     int64 `json:"date"`
+			Measures []struct {
+				Value int `json:"value"`
+				Type  int `json:"type"`
+				Unit  int `json:"unit"`
+			} `json:"measures"`
+		} `json:"measuregrps"`
+	} `json:"body"`
+}
+
+// fetchMeasures returns every measuregrp entry for the given type in
+// [from, until].
+func (c *Client) fetchMeasures(ctx context.Context, user string, measType int, from, until time.Time) (measureResponse, error) {
+	client, err := c.httpClient(ctx, user)
+	if err != nil {
+		return measureResponse{}, err
+	}
+
+	reqURL := fmt.Sprintf("%s/measure?action=getmeas&meastype=%d&category=1&startdate=%d&enddate=%d",
+		APIBaseURL, measType, from.Unix(), until.Unix())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return measureResponse{}, fmt.Errorf("error creating request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return measureResponse{}, fmt.Errorf("error fetching %s: %w", reqURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return measureResponse{}, fmt.Errorf("withings request failed with status code: %d", resp.StatusCode)
+	}
+
+	var out measureResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return measureResponse{}, fmt.Errorf("error decoding response: %w", err)
+	}
+	if out.Status != 0 {
+		return measureResponse{}, fmt.Errorf("withings API returned status %d", out.Status)
+	}
+	return out, nil
+}
+
+// FetchWeightRange returns weight in kilograms, keyed by "2006-01-02" date,
+// for every day Withings has a measurement in [from, until].
+func (c *Client) FetchWeightRange(ctx context.Context, user string, from, until time.Time) (map[string]float64, error) {
+	return c.fetchMeasureRange(ctx, user, measTypeWeight, from, until)
+}
+
+// FetchBodyFatRange returns body fat percentage, keyed by "2006-01-02"
+// date, for every day Withings has a measurement in [from, until].
+func (c *Client) FetchBodyFatRange(ctx context.Context, user string, from, until time.Time) (map[string]float64, error) {
+	return c.fetchMeasureRange(ctx, user, measTypeFatRatio, from, until)
+}
+
+func (c *Client) fetchMeasureRange(ctx context.Context, user string, measType int, from, until time.Time) (map[string]float64, error) {
+	resp, err := c.fetchMeasures(ctx, user, measType, from, until)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]float64)
+	for _, group := range resp.Body.MeasureGroups {
+		date := time.Unix(group.Date, 0).UTC().Format("2006-01-02")
+		for _, measure := range group.Measures {
+			if measure.Type != measType {
+				continue
+			}
+			values[date] = float64(measure.Value) * pow10(measure.Unit)
+		}
+	}
+	return values, nil
+}
+
+func pow10(exp int) float64 {
+	result := 1.0
+	if exp >= 0 {
+		for i := 0; i < exp; i++ {
+			result *= 10
+		}
+		return result
+	}
+	for i := 0; i < -exp; i++ {
+		result /= 10
+	}
+	return result
+}